@@ -0,0 +1,24 @@
+// Command disksinfo-capture writes a fixture archive of the files read by the linux loader,
+// to reproduce the disks of a machine in tests.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/mh-cbon/disksinfo/diskinfo"
+)
+
+func main() {
+	out := flag.String("o", "disksinfo-fixture.tar.gz", "the archive to write")
+	flag.Parse()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if err := diskinfo.CaptureFixture(f, diskinfo.Roots{}); err != nil {
+		panic(err)
+	}
+}
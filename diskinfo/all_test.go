@@ -1,8 +1,28 @@
 package diskinfo
 
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
 type parseTable struct {
 	in        string
 	expectErr error
 	expectOut []*Properties
 	path      string // for ls test
 }
+
+// writeFiles creates a tree of files under root, such as a sysfs fixture.
+func writeFiles(t testing.TB, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package diskinfo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DisksLoader can load the disks of the system, with their partitions and volumes.
+type DisksLoader interface {
+	LoadDisks() ([]*Disk, error)
+	// LoadDisksContext loads the disks until ctx is done.
+	LoadDisksContext(ctx context.Context) ([]*Disk, error)
+}
+
+// Disk is a whole block device, such as sda or nvme0n1.
+type Disk struct {
+	Name        string
+	Path        string
+	MajorMinor  string
+	Size        ByteSize
+	IsRemovable bool
+	// Transport is the hot-pluggable bus the disk is attached to, usb, mmc, ieee1394 or thunderbolt,
+	// it is empty for the other buses.
+	Transport  string `json:",omitempty"`
+	Partitions []*Partition
+	// Volume is the filesystem written on the whole disk, when it has no partition table.
+	Volume *Volume `json:",omitempty"`
+}
+
+// Partition is a partition of a Disk, such as sda1 or nvme0n1p1.
+type Partition struct {
+	Name       string
+	Path       string
+	MajorMinor string
+	Number     int
+	Start      ByteSize
+	Size       ByteSize
+	Disk       *Disk   `json:"-"`
+	Volume     *Volume `json:",omitempty"`
+}
+
+// Volume is a filesystem found on a Disk or a Partition.
+type Volume struct {
+	*Properties
+	Disk      *Disk      `json:"-"`
+	Partition *Partition `json:"-"`
+}
+
+// IsMounted tells if a volume of the disk, or of its partitions, is mounted.
+func (d *Disk) IsMounted() bool {
+	if d.Volume != nil && d.Volume.MountPath != "" {
+		return true
+	}
+	for _, p := range d.Partitions {
+		if p.Volume != nil && p.Volume.MountPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FindPartition search a partition of the disk by its path.
+func (d *Disk) FindPartition(path string) *Partition {
+	for _, p := range d.Partitions {
+		if p.Path == path {
+			return p
+		}
+	}
+	return nil
+}
+
+// sysfs sizes are always counted in 512 bytes sectors,
+// whatever the logical block size of the device.
+const sectorSize = 512
+
+// BlockDevicesReader reads the block devices of a sysfs tree.
+type BlockDevicesReader struct {
+	sys string
+}
+
+// NewBlockDevicesReader reads the block devices of the sysfs tree mounted at sys.
+func NewBlockDevicesReader(sys string) *BlockDevicesReader {
+	return &BlockDevicesReader{sys: sys}
+}
+
+// Read returns the disks found in /sys/block, with their partitions.
+// The path of a device-mapper disk is /dev/mapper/<name>, rather than /dev/dm-N.
+// A partition is a sub directory of the disk with a partition attribute,
+// as /sys/class/block/*/partition.
+func (l *BlockDevicesReader) Read() ([]*Disk, error) {
+	var ret []*Disk
+
+	dir := filepath.Join(l.sys, "block")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return ret, err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		diskDir := filepath.Join(dir, name)
+		size, _ := strconv.ParseUint(readAttr(diskDir, "size"), 10, 64)
+		if size == 0 {
+			// empty loop devices, card readers without media...
+			continue
+		}
+		d := &Disk{
+			Name:       name,
+			Path:       devPath(name),
+			MajorMinor: readAttr(diskDir, "dev"),
+			Size:       ByteSize(size * sectorSize),
+			Transport:  diskTransport(diskDir),
+		}
+		d.IsRemovable = isRemovable(diskDir, d.Transport)
+		if dm := readAttr(diskDir, "dm/name"); dm != "" {
+			d.Path = "/dev/mapper/" + dm
+		}
+		subs, err := ioutil.ReadDir(diskDir)
+		if err != nil {
+			return ret, err
+		}
+		for _, s := range subs {
+			partDir := filepath.Join(diskDir, s.Name())
+			number := readAttr(partDir, "partition")
+			if number == "" {
+				continue
+			}
+			p := &Partition{
+				Name:       s.Name(),
+				Path:       devPath(s.Name()),
+				MajorMinor: readAttr(partDir, "dev"),
+				Disk:       d,
+			}
+			p.Number, _ = strconv.Atoi(number)
+			start, _ := strconv.ParseUint(readAttr(partDir, "start"), 10, 64)
+			p.Start = ByteSize(start * sectorSize)
+			size, _ := strconv.ParseUint(readAttr(partDir, "size"), 10, 64)
+			p.Size = ByteSize(size * sectorSize)
+			d.Partitions = append(d.Partitions, p)
+		}
+		sort.Slice(d.Partitions, func(i, j int) bool {
+			return d.Partitions[i].Number < d.Partitions[j].Number
+		})
+		ret = append(ret, d)
+	}
+
+	return ret, nil
+}
+
+// devPath returns the /dev path of a sysfs block device name,
+// sysfs replaces the slashes of device names with bangs (cciss!c0d0).
+func devPath(name string) string {
+	return "/dev/" + strings.Replace(name, "!", "/", -1)
+}
+
+var (
+	transportRe = regexp.MustCompile(`^(usb|mmc|fw|domain)[0-9]+$`)
+	pciRe       = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-9a-f]$`)
+	transports  = map[string]string{"usb": "usb", "mmc": "mmc", "fw": "ieee1394", "domain": "thunderbolt"}
+)
+
+// diskTransport finds the transport of a disk in its sysfs device path,
+// such as /sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdb.
+// The bus closest to the disk wins, a PCIe device tunnelled through Thunderbolt
+// is found by the removable attribute of its external facing PCI ports.
+func diskTransport(diskDir string) string {
+	dir, err := filepath.EvalSymlinks(diskDir)
+	if err != nil {
+		return ""
+	}
+	var transport string
+	for _, name := range strings.Split(filepath.ToSlash(dir), "/") {
+		if m := transportRe.FindStringSubmatch(name); m != nil {
+			transport = transports[m[1]]
+		}
+	}
+	if transport != "" {
+		return transport
+	}
+	for d := dir; d != filepath.Dir(d); d = filepath.Dir(d) {
+		if pciRe.MatchString(filepath.Base(d)) && readAttr(d, "removable") == "removable" {
+			return "thunderbolt"
+		}
+	}
+	return ""
+}
+
+// isRemovable tells the disk can be removed: its media is removable, or polled for changes,
+// such as an optical drive or a card reader, or it is attached to a hot-pluggable bus other than usb.
+// An usb disk is removable when it says so, usb fixed disks are not,
+// a mmc disk when it is a SD card, soldered eMMC disks are not.
+func isRemovable(diskDir, transport string) bool {
+	if readAttr(diskDir, "removable") == "1" {
+		return true
+	}
+	if poll, err := strconv.Atoi(readAttr(diskDir, "events_poll_msecs")); err == nil && poll > 0 {
+		return true
+	}
+	switch transport {
+	case "", "usb":
+		return false
+	case "mmc":
+		return readAttr(diskDir, "device/type") == "SD"
+	}
+	return true
+}
+
+// readAttr returns the trimmed content of a sysfs attribute, or an empty string.
+func readAttr(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// attachVolumes links the properties to the disks and partitions they are written on,
+// they are matched by their major:minor numbers, or their path.
+func attachVolumes(disks []*Disk, props PropertiesList) {
+	find := func(path, majorMinor string) *Properties {
+		for _, p := range props {
+			if majorMinor != "" && p.MajorMinor == majorMinor {
+				return p
+			}
+		}
+		return props.FindByPath(path)
+	}
+	for _, d := range disks {
+		if p := find(d.Path, d.MajorMinor); p != nil {
+			d.Volume = &Volume{Properties: p, Disk: d}
+		}
+		for _, part := range d.Partitions {
+			if p := find(part.Path, part.MajorMinor); p != nil {
+				part.Volume = &Volume{Properties: p, Disk: d, Partition: part}
+			}
+		}
+	}
+}
+
+// readPartitions lists every partition of the disks, mounted or not, with the size of the partition.
+// A disk without partitions, such as a disk formatted as a whole, a RAID array or a device-mapper device,
+// is listed as a partition.
+// The devices held by another device, such as an opened LUKS container or a RAID member, are skipped,
+// the device stacked on them is listed instead.
+func readPartitions(roots Roots) ([]*Properties, error) {
+	var ret []*Properties
+
+	sys := roots.Path("/sys")
+	disks, err := NewBlockDevicesReader(sys).Read()
+	if err != nil {
+		return ret, err
+	}
+	held := map[string]bool{}
+	for _, d := range disks {
+		slaves, _ := ioutil.ReadDir(filepath.Join(sys, "block", d.Name, "slaves"))
+		for _, s := range slaves {
+			held[s.Name()] = true
+		}
+	}
+	add := func(path, majorMinor, disk string, number int, size ByteSize) {
+		p := NewProperties()
+		p.Path = path
+		p.MajorMinor = majorMinor
+		p.Disk = disk
+		p.PartitionNumber = number
+		p.TotalBytes = size
+		p.Size = size.String()
+		ret = append(ret, p)
+	}
+	for _, d := range disks {
+		if len(d.Partitions) == 0 && !held[d.Name] {
+			add(d.Path, d.MajorMinor, "", 0, d.Size)
+		}
+		for _, part := range d.Partitions {
+			if !held[part.Name] {
+				add(part.Path, part.MajorMinor, d.Path, part.Number, part.Size)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// readRemovable lists the disks and their partitions with their removability and transport.
+func readRemovable(roots Roots) ([]*Properties, error) {
+	var ret []*Properties
+
+	disks, err := NewBlockDevicesReader(roots.Path("/sys")).Read()
+	if err != nil {
+		return ret, err
+	}
+	add := func(d *Disk, path, majorMinor string) {
+		p := NewProperties()
+		p.Path = path
+		p.MajorMinor = majorMinor
+		p.IsRemovable = d.IsRemovable
+		p.Transport = d.Transport
+		ret = append(ret, p)
+	}
+	for _, d := range disks {
+		add(d, d.Path, d.MajorMinor)
+		for _, part := range d.Partitions {
+			add(d, part.Path, part.MajorMinor)
+		}
+	}
+	return ret, nil
+}
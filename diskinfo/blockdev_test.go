@@ -0,0 +1,227 @@
+package diskinfo
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestBlockDevicesReader(t *testing.T) {
+	sys := t.TempDir()
+	writeFiles(t, sys, map[string]string{
+		"block/sda/dev":              "8:0\n",
+		"block/sda/size":             "1953525168\n",
+		"block/sda/removable":        "0\n",
+		"block/sda/sda1/dev":         "8:1\n",
+		"block/sda/sda1/partition":   "1\n",
+		"block/sda/sda1/start":       "2048\n",
+		"block/sda/sda1/size":        "1048576\n",
+		"block/sda/sda2/dev":         "8:2\n",
+		"block/sda/sda2/partition":   "2\n",
+		"block/sda/sda2/start":       "1050624\n",
+		"block/sda/sda2/size":        "1952474544\n",
+		"block/sda/queue/rotational": "1\n",
+		"block/sdb/dev":              "8:16\n",
+		"block/sdb/size":             "60437492\n",
+		"block/sdb/removable":        "1\n",
+		"block/dm-0/dev":             "253:0\n",
+		"block/dm-0/size":            "67108864\n",
+		"block/loop0/dev":            "7:0\n",
+		"block/loop0/size":           "0\n",
+	})
+
+	disks, err := NewBlockDevicesReader(sys).Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(disks) != 3 {
+		t.Fatalf("Expected 3 disks, got %v", len(disks))
+	}
+
+	sda := disks[1]
+	if sda.Path != "/dev/sda" || sda.MajorMinor != "8:0" || sda.Size != 1000204886016 || sda.IsRemovable {
+		t.Errorf("Unexpected disk %#v", sda)
+	}
+	if len(sda.Partitions) != 2 {
+		t.Fatalf("Expected 2 partitions, got %v", len(sda.Partitions))
+	}
+	p := sda.FindPartition("/dev/sda2")
+	if p == nil || p.Number != 2 || p.Start != 1050624*512 || p.Size != 1952474544*512 || p.Disk != sda {
+		t.Errorf("Unexpected partition %#v", p)
+	}
+	if !disks[2].IsRemovable || len(disks[2].Partitions) != 0 {
+		t.Errorf("Unexpected disk %#v", disks[2])
+	}
+
+	props := PropertiesList{
+		&Properties{Path: "/dev/mapper/fedora-root", MountPath: "/", MajorMinor: "253:0"},
+		&Properties{Path: "/dev/sda1", Label: "Recovery"},
+		&Properties{Path: "/dev/sda2", MountPath: "/home", MajorMinor: "8:2"},
+	}
+	attachVolumes(disks, props)
+	if disks[0].Volume == nil || disks[0].Volume.MountPath != "/" {
+		t.Errorf("Expected dm-0 to hold the root volume")
+	}
+	if v := sda.Partitions[0].Volume; v == nil || v.Label != "Recovery" || v.Partition != sda.Partitions[0] {
+		t.Errorf("Expected sda1 to hold the Recovery volume")
+	}
+	if !sda.IsMounted() || disks[2].IsMounted() {
+		t.Errorf("Unexpected mount state")
+	}
+}
+
+func TestLinuxLoaderPartitionTables(t *testing.T) {
+	root := t.TempDir()
+	mbr := make([]byte, 512)
+	entry := mbr[446:]
+	entry[4] = 0x83
+	binary.LittleEndian.PutUint32(entry[8:], 2048)
+	binary.LittleEndian.PutUint32(entry[12:], 1048576)
+	binary.LittleEndian.PutUint16(mbr[510:], 0xaa55)
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo":          "28 1 8:1 / /srv rw,relatime shared:1 - ext4 /dev/sda1 rw\n",
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda1/dev":       "8:1\n",
+		"sys/block/sda/sda1/partition": "1\n",
+		"dev/sda":                      string(mbr),
+	})
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root)}
+	res, err := loader.Load()
+	if err != nil || len(res) != 1 || res[0].PartitionType != "" {
+		t.Fatalf("Expected the partition tables not to be read, got %#v %v", res, err)
+	}
+
+	loader.ReadPartitionTables = true
+	res, err = loader.Load()
+	if err != nil || len(res) != 1 {
+		t.Fatalf("Unexpected result %#v %v", res, err)
+	}
+	if p := res[0]; p.PartitionType != "0x83" || p.PartitionNumber != 1 || p.Disk != "/dev/sda" || p.SourceOf(FieldPartitionType) != "partition-table" {
+		t.Errorf("Unexpected partition %#v", p)
+	}
+}
+
+func TestLinuxLoaderProbeFilesystems(t *testing.T) {
+	root := t.TempDir()
+	ext4 := make([]byte, 2048)
+	binary.LittleEndian.PutUint16(ext4[1024+0x38:], 0xef53)
+	binary.LittleEndian.PutUint32(ext4[1024+0x60:], 0x40)
+	copy(ext4[1024+0x68:], "\x2f\x3c\x5a\x1e\x9b\x7d\x4e\x61\x8a\x0f\x3d\x2c\x1b\x4a\x5e\x6f")
+	copy(ext4[1024+0x78:], "backup")
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo":          "28 1 8:1 / /srv rw,relatime shared:1 - ext4 /dev/sda1 rw\n",
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda1/dev":       "8:1\n",
+		"sys/block/sda/sda1/partition": "1\n",
+		"sys/block/sda/sda2/dev":       "8:2\n",
+		"sys/block/sda/sda2/partition": "2\n",
+		"dev/sda1":                     "",
+		"dev/sda2":                     string(ext4),
+	})
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root)}
+	res, err := loader.Load()
+	if err != nil || len(res) != 2 || res[1].FSType != "" {
+		t.Fatalf("Expected the superblocks not to be read, got %#v %v", res, err)
+	}
+
+	loader.ProbeFilesystems = true
+	res, err = loader.Load()
+	if err != nil || len(res) != 2 {
+		t.Fatalf("Unexpected result %#v %v", res, err)
+	}
+	p := res[1]
+	if p.Path != "/dev/sda2" || p.FSType != "ext4" || p.Label != "backup" || p.UUID != "2f3c5a1e-9b7d-4e61-8a0f-3d2c1b4a5e6f" {
+		t.Errorf("Unexpected partition %#v", p)
+	}
+	if p.SourceOf(FieldLabel) != "superblock" {
+		t.Errorf("Expected the label to come from the superblock, got %q", p.SourceOf(FieldLabel))
+	}
+}
+
+func TestLinuxLoaderUnmountedPartitions(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo":          "28 1 8:1 / /srv rw,relatime shared:1 - ext4 /dev/sda1 rw\n",
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda1/dev":       "8:1\n",
+		"sys/block/sda/sda1/partition": "1\n",
+		"sys/block/sda/sda1/size":      "1048576\n",
+		"sys/block/sda/sda2/dev":       "8:2\n",
+		"sys/block/sda/sda2/partition": "2\n",
+		"sys/block/sda/sda2/size":      "4194304\n",
+		"sys/block/sda/sda3/dev":       "8:3\n",
+		"sys/block/sda/sda3/partition": "3\n",
+		"sys/block/sda/sda3/size":      "4194304\n",
+		"sys/block/md0/dev":            "9:0\n",
+		"sys/block/md0/size":           "4194304\n",
+		"sys/block/md0/slaves/sda3":    "",
+		"sys/block/sdb/dev":            "8:16\n",
+		"sys/block/sdb/size":           "8388608\n",
+		"run/udev/data/b8:16":          "E:ID_FS_TYPE=xfs\nE:ID_FS_LABEL=backup\n",
+	})
+
+	res, err := (&LinuxLoader{Roots: FixtureRoots(root)}).Load()
+	if err != nil || len(res) != 4 {
+		t.Fatalf("Unexpected result %#v %v", res, err)
+	}
+	l := PropertiesList(res)
+	if p := l.FindByPath("/dev/sda1"); p == nil || !p.Mounted || p.TotalBytes != 0 || p.PartitionNumber != 1 || p.Disk != "/dev/sda" {
+		t.Errorf("Expected sda1 to be mounted without usage, got %#v", p)
+	}
+	p := l.FindByPath("/dev/sda2")
+	if p == nil || p.Mounted || p.TotalBytes != 2*1024*1024*1024 || p.Size != "2.0G" || p.PartitionNumber != 2 {
+		t.Fatalf("Expected sda2 to be listed unmounted with its size, got %#v", p)
+	}
+	if p.SourceOf(FieldTotalBytes) != "partitions" {
+		t.Errorf("Expected the size to come from the partitions, got %q", p.SourceOf(FieldTotalBytes))
+	}
+	if l.FindByPath("/dev/sda3") != nil {
+		t.Errorf("Expected the RAID member not to be listed")
+	}
+	if p := l.FindByPath("/dev/md0"); p == nil || p.Mounted || p.TotalBytes != 2*GiB || p.Disk != "" || p.PartitionNumber != 0 {
+		t.Errorf("Expected the RAID array stacked on sda3 to be listed, got %#v", p)
+	}
+	if p := l.FindByPath("/dev/sdb"); p == nil || p.Mounted || p.TotalBytes != 4*GiB || p.Label != "backup" || p.FSType != "xfs" {
+		t.Errorf("Expected the unpartitioned disk to be listed, got %#v", p)
+	}
+	if l.FindByPath("/dev/sda") != nil {
+		t.Errorf("Expected the partitioned disk not to be listed")
+	}
+}
+
+func TestLinuxLoaderBtrfs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": `28 1 0:45 /root / rw,relatime shared:1 - btrfs /dev/sda3 rw,seclabel,compress=zstd:1,space_cache=v2,subvolid=257,subvol=/root
+65 28 0:45 /home /home rw,relatime shared:30 - btrfs /dev/sda3 rw,seclabel,compress=zstd:1,space_cache=v2,subvolid=256,subvol=/home
+`,
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda3/dev":       "8:3\n",
+		"sys/block/sda/sda3/partition": "3\n",
+		"sys/block/sda/sda3/size":      "4194304\n",
+		"run/udev/data/b8:3":           "E:ID_FS_TYPE=btrfs\nE:ID_FS_UUID=5b1c6a2e-0d3f-4e8a-b7c9-2f4e6a8c0d1e\nE:ID_FS_LABEL=fedora_localhost-live\n",
+	})
+
+	res, err := (&LinuxLoader{Roots: FixtureRoots(root)}).Load()
+	// the subvolumes of a device are listed as its mounts.
+	if err != nil || len(res) != 2 {
+		t.Fatalf("Expected sda3 to be listed for each subvolume, got %#v %v", res, err)
+	}
+	for i, mountPath := range []string{"/", "/home"} {
+		p := res[i]
+		if p.Path != "/dev/sda3" || p.MountPath != mountPath || !p.Mounted || p.MajorMinor != "0:45" {
+			t.Errorf("Unexpected mount %#v", p)
+		}
+		if p.Label != "fedora_localhost-live" || p.UUID != "5b1c6a2e-0d3f-4e8a-b7c9-2f4e6a8c0d1e" {
+			t.Errorf("%v: expected the udev label and UUID, got %q %q", mountPath, p.Label, p.UUID)
+		}
+		if p.TotalBytes != 0 || p.PartitionNumber != 3 || p.SourceOf(FieldPartitionNumber) != "partitions" {
+			t.Errorf("%v: expected the partition to complete the mount without its size, got %#v", mountPath, p)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package diskinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is an exact quantity of bytes.
+// It marshals to JSON as a number, it unmarshals from a number or a human readable string.
+type ByteSize uint64
+
+// Binary (IEC) multiples of a byte.
+const (
+	KiB ByteSize = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+	EiB
+)
+
+// Decimal (SI) multiples of a byte.
+const (
+	KB ByteSize = 1000
+	MB          = KB * 1000
+	GB          = MB * 1000
+	TB          = GB * 1000
+	PB          = TB * 1000
+	EB          = PB * 1000
+)
+
+var byteUnits = map[string]ByteSize{
+	"":    1,
+	"B":   1,
+	"K":   KiB,
+	"M":   MiB,
+	"G":   GiB,
+	"T":   TiB,
+	"P":   PiB,
+	"E":   EiB,
+	"KIB": KiB,
+	"MIB": MiB,
+	"GIB": GiB,
+	"TIB": TiB,
+	"PIB": PiB,
+	"EIB": EiB,
+	"KB":  KB,
+	"MB":  MB,
+	"GB":  GB,
+	"TB":  TB,
+	"PB":  PB,
+	"EB":  EB,
+}
+
+// ParseByteSize parses a size as printed by df -h, wmic or powershell,
+// such as 1,9G, 932G, 126 GiB, 2.0 TB or 1000204886016.
+// Single letter units are binary multiples, as df uses them.
+// A comma is read as a decimal separator.
+func ParseByteSize(s string) (ByteSize, error) {
+	v := strings.TrimSpace(s)
+	i := 0
+	for i < len(v) && (v[i] >= '0' && v[i] <= '9' || v[i] == '.' || v[i] == ',') {
+		i++
+	}
+	num := strings.Replace(v[:i], ",", ".", 1)
+	unit, ok := byteUnits[strings.ToUpper(strings.TrimSpace(v[i:]))]
+	if num == "" || !ok {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/uint64(unit) {
+			return 0, fmt.Errorf("byte size %q overflows", s)
+		}
+		return ByteSize(n) * unit, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	f = math.Round(f * float64(unit))
+	if f >= math.MaxUint64 {
+		return 0, fmt.Errorf("byte size %q overflows", s)
+	}
+	return ByteSize(f), nil
+}
+
+// String formats the size as df -h does, such as 1.9G,
+// with binary multiples and a dot as the decimal separator.
+func (b ByteSize) String() string {
+	return b.format(1024, sizeUnits, "")
+}
+
+// IEC formats the size with binary multiples, such as 1.9 GiB.
+func (b ByteSize) IEC() string {
+	return b.format(1024, []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}, " ")
+}
+
+// SI formats the size with decimal multiples, such as 2.0 GB.
+func (b ByteSize) SI() string {
+	return b.format(1000, []string{"KB", "MB", "GB", "TB", "PB", "EB"}, " ")
+}
+
+var sizeUnits = []string{"K", "M", "G", "T", "P", "E"}
+
+// format rounds up the size as df does,
+// one decimal is shown for values lower than 10.
+func (b ByteSize) format(base float64, units []string, sep string) string {
+	if float64(b) < base {
+		if sep != "" {
+			return strconv.FormatUint(uint64(b), 10) + sep + "B"
+		}
+		return strconv.FormatUint(uint64(b), 10)
+	}
+	f := float64(b)
+	i := -1
+	for f >= base && i < len(units)-1 {
+		f /= base
+		i++
+	}
+	r := roundUp(f)
+	// the unit is chosen after rounding, 1023.9M is 1.0G, not 1024M.
+	if r >= base && i < len(units)-1 {
+		r = roundUp(r / base)
+		i++
+	}
+	if r < 10 {
+		return strconv.FormatFloat(r, 'f', 1, 64) + sep + units[i]
+	}
+	return strconv.FormatFloat(r, 'f', 0, 64) + sep + units[i]
+}
+
+// roundUp rounds up f to one decimal below 10, to a whole number above.
+func roundUp(f float64) float64 {
+	if r := math.Ceil(f*10) / 10; r < 10 {
+		return r
+	}
+	return math.Ceil(f)
+}
+
+// MarshalJSON writes the exact number of bytes.
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(b), 10)), nil
+}
+
+// UnmarshalJSON reads a number of bytes, or a human readable size.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	// null is a no-op, as for the standard types.
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		n, err := strconv.ParseUint(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid byte size %s", data)
+		}
+		*b = ByteSize(n)
+		return nil
+	}
+	v, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
@@ -0,0 +1,106 @@
+package diskinfo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	testsTable := []struct {
+		in        string
+		expect    ByteSize
+		expectErr bool
+	}{
+		{"0", 0, false},
+		{"1000204886016", 1000204886016, false},
+		{"932G", 932 * GiB, false},
+		{"1,9G", 2040109466, false},
+		{"1.9G", 2040109466, false},
+		{"54M", 54 * MiB, false},
+		{"126 GiB", 126 * GiB, false},
+		{"2.0 TB", 2 * TB, false},
+		{"512 B", 512, false},
+		{" 4,1G ", 4402341478, false},
+		{"", 0, true},
+		{"G", 0, true},
+		{"12 parsecs", 0, true},
+		{"1.2.3G", 0, true},
+		{"99999999999E", 0, true},
+	}
+	for i, testTable := range testsTable {
+		got, err := ParseByteSize(testTable.in)
+		if testTable.expectErr {
+			if err == nil {
+				t.Errorf("Test(%v): ParseByteSize(%q) expected an error, got %v", i, testTable.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test(%v): ParseByteSize(%q) unexpected error %v", i, testTable.in, err)
+		} else if got != testTable.expect {
+			t.Errorf("Test(%v): ParseByteSize(%q) expected %v, got %v", i, testTable.in, uint64(testTable.expect), uint64(got))
+		}
+	}
+}
+
+func TestByteSizeFormat(t *testing.T) {
+	testsTable := []struct {
+		in  ByteSize
+		df  string
+		iec string
+		si  string
+	}{
+		{0, "0", "0 B", "0 B"},
+		{1023, "1023", "1023 B", "1.1 KB"},
+		{1024, "1.0K", "1.0 KiB", "1.1 KB"},
+		{54 * MiB, "54M", "54 MiB", "57 MB"},
+		{2040109465, "1.9G", "1.9 GiB", "2.1 GB"},
+		{32 * GiB, "32G", "32 GiB", "35 GB"},
+		{1000204886016, "932G", "932 GiB", "1.1 TB"},
+		{GiB - 1, "1.0G", "1.0 GiB", "1.1 GB"},
+		{999999, "977K", "977 KiB", "1.0 MB"},
+		{10*MiB - 1, "10M", "10 MiB", "11 MB"},
+	}
+	for i, testTable := range testsTable {
+		if got := testTable.in.String(); got != testTable.df {
+			t.Errorf("Test(%v): String() expected %q, got %q", i, testTable.df, got)
+		}
+		if got := testTable.in.IEC(); got != testTable.iec {
+			t.Errorf("Test(%v): IEC() expected %q, got %q", i, testTable.iec, got)
+		}
+		if got := testTable.in.SI(); got != testTable.si {
+			t.Errorf("Test(%v): SI() expected %q, got %q", i, testTable.si, got)
+		}
+	}
+}
+
+func TestByteSizeJSON(t *testing.T) {
+	p := &Properties{Size: "1.9G", TotalBytes: 2040109465}
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if out["Size"] != "1.9G" || out["TotalBytes"] != float64(2040109465) {
+		t.Errorf("Unexpected json %s", b)
+	}
+
+	var in struct {
+		A ByteSize
+		B ByteSize
+	}
+	if err := json.Unmarshal([]byte(`{"A": 1024, "B": "1,5K"}`), &in); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if in.A != KiB || in.B != 1536 {
+		t.Errorf("Unexpected values %v %v", uint64(in.A), uint64(in.B))
+	}
+
+	in.A = KiB
+	if err := json.Unmarshal([]byte(`{"A": null}`), &in); err != nil || in.A != KiB {
+		t.Errorf("Expected null to be a no-op, got %v %v", uint64(in.A), err)
+	}
+}
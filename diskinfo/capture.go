@@ -0,0 +1,234 @@
+package diskinfo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxCapturedFile is the maximum size of a file copied into a fixture archive.
+const maxCapturedFile = 1 << 20
+
+// sysfs directories of a block device that are useless to the loaders.
+var skippedSysDirs = map[string]bool{
+	"trace":     true,
+	"power":     true,
+	"mq":        true,
+	"integrity": true,
+}
+
+// CaptureFixture writes a gzipped tar archive of the files a LinuxLoader reads,
+// from the live system or the given roots.
+// Once extracted, use FixtureRoots to load the captured machine.
+func CaptureFixture(w io.Writer, roots Roots) error {
+	gz := gzip.NewWriter(w)
+	c := &capturer{tw: tar.NewWriter(gz), roots: roots, seen: map[string]bool{}}
+
+	c.file("/proc/self/mountinfo")
+	c.file("/proc/mdstat")
+	c.blockLinks("/sys/block")
+	c.blockLinks("/sys/class/block")
+	c.tree("/dev/disk")
+	c.tree("/dev/mapper")
+	c.tree("/run/udev/data")
+
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type capturer struct {
+	tw    *tar.Writer
+	roots Roots
+	seen  map[string]bool
+	err   error
+}
+
+// file copies a regular file, it is skipped when it can not be read,
+// as many sysfs attributes are.
+func (c *capturer) file(path string) {
+	if c.err != nil || c.seen[path] {
+		return
+	}
+	c.seen[path] = true
+	f, err := os.Open(c.roots.Path(path))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxCapturedFile))
+	if err != nil {
+		return
+	}
+	c.write(&tar.Header{Name: path[1:], Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}, b)
+}
+
+func (c *capturer) link(path, target string) {
+	if c.err != nil || c.seen[path] {
+		return
+	}
+	c.seen[path] = true
+	c.write(&tar.Header{Name: path[1:], Mode: 0777, Linkname: target, Typeflag: tar.TypeSymlink}, nil)
+}
+
+func (c *capturer) write(h *tar.Header, b []byte) {
+	if err := c.tw.WriteHeader(h); err != nil {
+		c.err = err
+		return
+	}
+	if _, err := c.tw.Write(b); err != nil {
+		c.err = err
+	}
+}
+
+// blockLinks captures the entries of /sys/block or /sys/class/block,
+// and the device directories they point to.
+func (c *capturer) blockLinks(dir string) {
+	entries, err := ioutil.ReadDir(c.roots.Path(dir))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := dir + "/" + e.Name()
+		if e.Mode()&os.ModeSymlink == 0 {
+			c.tree(path)
+			continue
+		}
+		target, err := os.Readlink(c.roots.Path(path))
+		if err != nil {
+			continue
+		}
+		c.link(path, target)
+		if !filepath.IsAbs(target) {
+			c.tree(filepath.Join(dir, target))
+			c.deviceAttrs(filepath.Join(dir, target))
+		}
+	}
+}
+
+// deviceAttrs captures the attributes outside of a block device directory which tell its transport,
+// the type of its device, such as a SD card, and the removable attribute of its ancestors,
+// such as the PCI ports of a Thunderbolt dock.
+func (c *capturer) deviceAttrs(diskDir string) {
+	if target, err := os.Readlink(c.roots.Path(diskDir + "/device")); err == nil && !filepath.IsAbs(target) {
+		c.file(filepath.Join(diskDir, target, "type"))
+	}
+	for d := filepath.Dir(diskDir); strings.HasPrefix(d, "/sys/"); d = filepath.Dir(d) {
+		c.file(d + "/removable")
+	}
+}
+
+// tree captures a directory recursively, without following symbolic links.
+func (c *capturer) tree(dir string) {
+	if c.err != nil || c.seen[dir] {
+		return
+	}
+	c.seen[dir] = true
+	entries, err := ioutil.ReadDir(c.roots.Path(dir))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := dir + "/" + e.Name()
+		switch {
+		case e.Mode()&os.ModeSymlink != 0:
+			if target, err := os.Readlink(c.roots.Path(path)); err == nil {
+				c.link(path, target)
+			}
+		case e.IsDir():
+			if !skippedSysDirs[e.Name()] {
+				c.tree(path)
+			}
+		case e.Mode().IsRegular() && e.Mode().Perm()&0444 != 0:
+			c.file(path)
+		}
+	}
+}
+
+// ExtractFixture extracts an archive written by CaptureFixture into dir.
+func ExtractFixture(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(h.Name)
+		if escapes(name) {
+			return fmt.Errorf("invalid fixture entry %q", h.Name)
+		}
+		path := filepath.Join(dir, name)
+		// a link extracted earlier must not lead the entry out of dir.
+		if err := checkInside(dir, filepath.Dir(path)); err != nil {
+			return fmt.Errorf("invalid fixture entry %q: %v", h.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		switch h.Typeflag {
+		case tar.TypeSymlink:
+			target := filepath.Clean(filepath.Join(filepath.Dir(name), filepath.FromSlash(h.Linkname)))
+			if filepath.IsAbs(h.Linkname) || escapes(target) {
+				return fmt.Errorf("invalid fixture link %q -> %q", h.Name, h.Linkname)
+			}
+			if err := os.Symlink(h.Linkname, path); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, b, 0644); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// escapes tells the clean relative path name leaves its root.
+func escapes(name string) bool {
+	return filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator))
+}
+
+// checkInside verifies the deepest existing directory of path, its links resolved, is inside dir.
+func checkInside(dir, path string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || escapes(rel) {
+		return fmt.Errorf("%v is outside of %v", resolved, root)
+	}
+	return nil
+}
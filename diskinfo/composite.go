@@ -0,0 +1,173 @@
+package diskinfo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Source is a source of partition properties, such as the mount table or the udev database.
+type Source interface {
+	Load(ctx context.Context) ([]*Properties, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context) ([]*Properties, error)
+
+// Load calls f.
+func (f SourceFunc) Load(ctx context.Context) ([]*Properties, error) {
+	return f(ctx)
+}
+
+// SourceInfo describes how the properties of a source are merged by a CompositeLoader.
+type SourceInfo struct {
+	// Name identifies the source in the errors.
+	Name string
+	// Priority orders the sources, they are merged from the lowest priority to the highest,
+	// the fields of a source override those of the sources of lower priority.
+	// Sources of the same priority are merged in their registration order.
+	Priority int
+	// Fields are the properties the source owns,
+	// they are merged into the partitions found by the sources of lower priority.
+	Fields FieldSet
+	// Policy tells how the fields are merged, it defaults to PreferNonEmpty.
+	Policy MergePolicy
+	// Adds selects the partitions of the source appended when no source of lower priority found them,
+	// none are appended when it is nil.
+	Adds func(p *Properties) bool
+}
+
+// AllPartitions is a SourceInfo.Adds appending every partition.
+func AllPartitions(p *Properties) bool {
+	return true
+}
+
+type registeredSource struct {
+	SourceInfo
+	source Source
+}
+
+// CompositeLoader loads partition properties from the registered sources, and merges them.
+// Every source is run, when some of them fail the properties of the others
+// are returned with a *LoadError.
+type CompositeLoader struct {
+	// SourceTimeout bounds the time spent on each source, zero means no limit.
+	SourceTimeout time.Duration
+	// Concurrency is the number of sources loaded at the same time, it defaults to DefaultConcurrency.
+	// The results are merged in the same order whatever it is.
+	Concurrency int
+
+	sources []registeredSource
+	// begin prepares the context of a load, such as to share a cache between its sources.
+	begin func(ctx context.Context) context.Context
+}
+
+// DefaultConcurrency is the number of sources a loader loads at the same time.
+const DefaultConcurrency = 4
+
+// NewCompositeLoader is a constructor.
+func NewCompositeLoader() *CompositeLoader {
+	return &CompositeLoader{}
+}
+
+// Register adds a source to the loader, it panics when a source with the same name is registered.
+func (c *CompositeLoader) Register(info SourceInfo, source Source) {
+	for _, s := range c.sources {
+		if s.Name == info.Name {
+			panic(fmt.Sprintf("diskinfo: source %q registered twice", info.Name))
+		}
+	}
+	c.sources = append(c.sources, registeredSource{SourceInfo: info, source: source})
+	sort.SliceStable(c.sources, func(i, j int) bool {
+		return c.sources[i].Priority < c.sources[j].Priority
+	})
+}
+
+// Sources returns the registered sources in their merge order.
+func (c *CompositeLoader) Sources() []SourceInfo {
+	var ret []SourceInfo
+	for _, s := range c.sources {
+		ret = append(ret, s.SourceInfo)
+	}
+	return ret
+}
+
+// Load returns the partitions found by the sources, with their properties.
+func (c *CompositeLoader) Load() ([]*Properties, error) {
+	return c.LoadContext(context.Background())
+}
+
+// LoadContext is Load, it gives up when ctx is done.
+func (c *CompositeLoader) LoadContext(ctx context.Context) ([]*Properties, error) {
+	if c.begin != nil {
+		ctx = c.begin(ctx)
+	}
+	results := c.loadSources(ctx)
+
+	var ret PropertiesList
+	loadErr := &LoadError{}
+	for i, s := range c.sources {
+		if err := results[i].err; err != nil {
+			if ctx.Err() != nil {
+				return ret, ctx.Err()
+			}
+			loadErr.Errors = append(loadErr.Errors, newSourceError(s.Name, err))
+			continue
+		}
+		temp := PropertiesList(results[i].props)
+		for _, p := range temp {
+			stampSource(p, s.Name)
+		}
+		var conflicts []*ConflictError
+		ret, conflicts = ret.MergeFields(temp, s.Fields, s.Policy)
+		if s.Policy == ErrorOnConflict {
+			loadErr.Conflicts = append(loadErr.Conflicts, conflicts...)
+		}
+		if s.Adds != nil {
+			var added PropertiesList
+			for _, p := range temp {
+				if s.Adds(p) {
+					added = append(added, p)
+				}
+			}
+			ret = ret.Append(added)
+		}
+	}
+
+	return ret, loadErr.errOrNil()
+}
+
+type sourceResult struct {
+	props []*Properties
+	err   error
+}
+
+// loadSources loads the sources with a pool of workers,
+// the results are in the order of the sources.
+func (c *CompositeLoader) loadSources(ctx context.Context) []sourceResult {
+	n := c.Concurrency
+	if n <= 0 {
+		n = DefaultConcurrency
+	}
+	ret := make([]sourceResult, len(c.sources))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n && w < len(c.sources); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				props, err := runSource(ctx, c.SourceTimeout, c.sources[i].source.Load)
+				ret[i] = sourceResult{props: props, err: err}
+			}
+		}()
+	}
+	for i := range c.sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return ret
+}
@@ -0,0 +1,68 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func staticSource(props ...*Properties) Source {
+	return SourceFunc(func(context.Context) ([]*Properties, error) {
+		return props, nil
+	})
+}
+
+func TestCompositeLoader(t *testing.T) {
+	c := NewCompositeLoader()
+	// registered first, merged last.
+	c.Register(SourceInfo{Name: "cmdb", Priority: 50, Fields: NewFieldSet(FieldLabel)}, staticSource(
+		&Properties{Path: "/dev/sda1", Label: "db-data"},
+		&Properties{Path: "/dev/sdz1", Label: "unknown"},
+	))
+	c.Register(SourceInfo{Name: "mounts", Adds: AllPartitions}, staticSource(
+		&Properties{Path: "/dev/sda1", MountPath: "/srv", Label: "data"},
+		&Properties{Path: "/dev/sda2", MountPath: "/"},
+	))
+	c.Register(SourceInfo{Name: "labels", Priority: 10, Fields: NewFieldSet(FieldLabel), Adds: hasLabel}, staticSource(
+		&Properties{Path: "/dev/sda2", Label: "root"},
+		&Properties{Path: "/dev/sdb1", Label: "backup"},
+		&Properties{Path: "/dev/sdb2"},
+	))
+	c.Register(SourceInfo{Name: "broken", Priority: 20}, SourceFunc(func(context.Context) ([]*Properties, error) {
+		return nil, errors.New("unreachable")
+	}))
+
+	var names []string
+	for _, s := range c.Sources() {
+		names = append(names, s.Name)
+	}
+	if got := fmt.Sprint(names); got != "[mounts labels broken cmdb]" {
+		t.Errorf("Unexpected merge order %v", got)
+	}
+
+	res, err := c.Load()
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || len(loadErr.Errors) != 1 || loadErr.Errors[0].Source != "broken" {
+		t.Fatalf("Expected the broken source to fail, got %v", err)
+	}
+	got := map[string]string{}
+	for _, p := range res {
+		got[p.Path] = p.Label
+	}
+	want := map[string]string{"/dev/sda1": "db-data", "/dev/sda2": "root", "/dev/sdb1": "backup"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestCompositeLoaderRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic")
+		}
+	}()
+	c := NewCompositeLoader()
+	c.Register(SourceInfo{Name: "cmdb"}, staticSource())
+	c.Register(SourceInfo{Name: "cmdb"}, staticSource())
+}
@@ -0,0 +1,253 @@
+package diskinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Encryption describes the dm-crypt encryption of a volume.
+type Encryption struct {
+	// Type is the dm-crypt type, such as LUKS1, LUKS2 or PLAIN.
+	Type string
+	// Version is the LUKS version of the header.
+	Version int `json:",omitempty"`
+	// Cipher is the cipher specification, such as aes-xts-plain64.
+	Cipher string `json:",omitempty"`
+	// UUID is the LUKS UUID of the header.
+	UUID string `json:",omitempty"`
+	// Device is the path of the encrypted device, such as /dev/sda3.
+	Device string
+	// Mapper is the path of the opened device-mapper device, it is empty while the device is locked.
+	Mapper string `json:",omitempty"`
+	// Locked tells the encrypted device is not opened.
+	Locked bool `json:",omitempty"`
+}
+
+// luksMagic starts the header of a LUKS device.
+var luksMagic = []byte("LUKS\xba\xbe")
+
+var errNotLuks = errors.New("not a LUKS header")
+
+// LuksHeader is the header of a LUKS encrypted device.
+type LuksHeader struct {
+	Version int
+	// Cipher is the cipher specification, such as aes-xts-plain64.
+	Cipher string
+	UUID   string
+	// Label is the label of a LUKS2 header.
+	Label string
+}
+
+// ReadLuksHeader reads the LUKS1 or LUKS2 header at the start of r.
+func ReadLuksHeader(r io.ReaderAt) (*LuksHeader, error) {
+	b := make([]byte, 512)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b[:6], luksMagic) {
+		return nil, errNotLuks
+	}
+	h := &LuksHeader{
+		Version: int(binary.BigEndian.Uint16(b[6:8])),
+		UUID:    cString(b[168:208]),
+	}
+	switch h.Version {
+	case 1:
+		// cipher-name[32] at 8, cipher-mode[32] at 40.
+		h.Cipher = cString(b[8:40]) + "-" + cString(b[40:72])
+	case 2:
+		h.Label = cString(b[24:72])
+		cipher, err := readLuks2Cipher(r, binary.BigEndian.Uint64(b[8:16]))
+		if err != nil {
+			return h, err
+		}
+		h.Cipher = cipher
+	default:
+		return nil, errNotLuks
+	}
+	return h, nil
+}
+
+// readLuks2Cipher reads the encryption of the first segment in the JSON area of a LUKS2 header,
+// it follows the 4096 bytes binary header, up to the header size.
+func readLuks2Cipher(r io.ReaderAt, hdrSize uint64) (string, error) {
+	if hdrSize <= 4096 || hdrSize > 4<<20 {
+		return "", errNotLuks
+	}
+	b := make([]byte, hdrSize-4096)
+	if _, err := r.ReadAt(b, 4096); err != nil {
+		return "", err
+	}
+	var doc struct {
+		Segments map[string]struct {
+			Encryption string `json:"encryption"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(bytes.TrimRight(b, "\x00"), &doc); err != nil {
+		return "", err
+	}
+	return doc.Segments["0"].Encryption, nil
+}
+
+// cString returns the NUL terminated string of b.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// cryptDevice is a block device of the sysfs tree.
+type cryptDevice struct {
+	name       string
+	path       string
+	majorMinor string
+	dir        string
+}
+
+// cryptScanner finds the dm-crypt devices and the LUKS devices of a system.
+type cryptScanner struct {
+	roots Roots
+	// readHeaders reads the LUKS headers of the devices, the udev database is used otherwise.
+	readHeaders bool
+	devices     map[string]*cryptDevice
+	seen        map[string]*Encryption
+}
+
+// readEncryption returns the properties of the volumes stacked on a dm-crypt device,
+// such as the opened mapper device and the LVM volumes on it,
+// and of the LUKS partitions that are not opened, as Locked.
+// The LUKS devices are found in the udev database, and by their header with readHeaders.
+func readEncryption(roots Roots, readHeaders bool) ([]*Properties, error) {
+	var ret []*Properties
+
+	sys := roots.Path("/sys")
+	disks, err := NewBlockDevicesReader(sys).Read()
+	if err != nil {
+		return ret, err
+	}
+	s := &cryptScanner{roots: roots, readHeaders: readHeaders, devices: map[string]*cryptDevice{}, seen: map[string]*Encryption{}}
+	var order []*cryptDevice
+	for _, d := range disks {
+		dev := &cryptDevice{name: d.Name, path: d.Path, majorMinor: d.MajorMinor, dir: filepath.Join(sys, "block", d.Name)}
+		s.devices[d.Name] = dev
+		order = append(order, dev)
+		for _, p := range d.Partitions {
+			dev := &cryptDevice{name: p.Name, path: p.Path, majorMinor: p.MajorMinor, dir: filepath.Join(sys, "block", d.Name, p.Name)}
+			s.devices[p.Name] = dev
+			order = append(order, dev)
+		}
+	}
+
+	opened := map[string]bool{}
+	for _, dev := range order {
+		e := s.encryption(dev, 0)
+		if e == nil {
+			continue
+		}
+		p := NewProperties()
+		p.Path = dev.path
+		p.MajorMinor = dev.majorMinor
+		p.Encryption = e
+		ret = append(ret, p)
+		opened[e.Device] = true
+	}
+	for _, dev := range order {
+		if opened[dev.path] || s.isCryptMapper(dev) {
+			continue
+		}
+		e := s.luks(dev)
+		if e == nil {
+			continue
+		}
+		e.Locked = true
+		p := NewProperties()
+		p.Path = dev.path
+		p.MajorMinor = dev.majorMinor
+		p.Encryption = e
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+// isCryptMapper tells if the device is a dm-crypt device, its dm/uuid is such as CRYPT-LUKS2-<uuid>-<name>.
+func (s *cryptScanner) isCryptMapper(dev *cryptDevice) bool {
+	return strings.HasPrefix(readAttr(dev.dir, "dm/uuid"), "CRYPT-")
+}
+
+// encryption returns the encryption of the device, or of the devices it is stacked on.
+func (s *cryptScanner) encryption(dev *cryptDevice, depth int) *Encryption {
+	if e, ok := s.seen[dev.name]; ok {
+		return e
+	}
+	if depth > 16 {
+		return nil
+	}
+	var ret *Encryption
+	slaves, _ := ioutil.ReadDir(filepath.Join(dev.dir, "slaves"))
+	if uuid := readAttr(dev.dir, "dm/uuid"); strings.HasPrefix(uuid, "CRYPT-") {
+		ret = &Encryption{Type: strings.SplitN(uuid, "-", 3)[1], Mapper: dev.path}
+		if len(slaves) > 0 {
+			if backing := s.devices[slaves[0].Name()]; backing != nil {
+				ret.Device = backing.path
+				if luks := s.luks(backing); luks != nil {
+					ret.Version, ret.Cipher, ret.UUID = luks.Version, luks.Cipher, luks.UUID
+				}
+			}
+		}
+	} else {
+		for _, slave := range slaves {
+			if d := s.devices[slave.Name()]; d != nil {
+				if e := s.encryption(d, depth+1); e != nil {
+					ret = e
+					break
+				}
+			}
+		}
+	}
+	s.seen[dev.name] = ret
+	return ret
+}
+
+// luks returns the LUKS encryption of a device, from the udev database,
+// completed with its header when the headers are read and the device can be read.
+func (s *cryptScanner) luks(dev *cryptDevice) *Encryption {
+	var ret *Encryption
+	if db, err := readUdevDB(s.roots, dev.majorMinor); err == nil && db["ID_FS_TYPE"] == "crypto_LUKS" {
+		ret = &Encryption{Type: "LUKS", UUID: db["ID_FS_UUID"], Device: dev.path}
+		if v, err := strconv.Atoi(db["ID_FS_VERSION"]); err == nil {
+			ret.Version = v
+		}
+	}
+	if s.readHeaders {
+		if h, err := readLuksHeaderFile(s.roots.Path(dev.path)); h != nil && err == nil {
+			if ret == nil {
+				ret = &Encryption{Device: dev.path}
+			}
+			ret.Version, ret.Cipher, ret.UUID = h.Version, h.Cipher, h.UUID
+		}
+	}
+	if ret != nil && ret.Version > 0 {
+		ret.Type = "LUKS" + strconv.Itoa(ret.Version)
+	}
+	return ret
+}
+
+// readLuksHeaderFile reads the LUKS header of the device at path.
+func readLuksHeaderFile(path string) (*LuksHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadLuksHeader(f)
+}
@@ -0,0 +1,125 @@
+package diskinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func luks1Header(cipher, mode, uuid string) []byte {
+	b := make([]byte, 1024)
+	copy(b, luksMagic)
+	binary.BigEndian.PutUint16(b[6:], 1)
+	copy(b[8:], cipher)
+	copy(b[40:], mode)
+	copy(b[72:], "sha256")
+	copy(b[168:], uuid)
+	return b
+}
+
+func luks2Header(label, uuid, encryption string) []byte {
+	b := make([]byte, 16384)
+	copy(b, luksMagic)
+	binary.BigEndian.PutUint16(b[6:], 2)
+	binary.BigEndian.PutUint64(b[8:], 16384)
+	copy(b[24:], label)
+	copy(b[168:], uuid)
+	copy(b[4096:], `{"keyslots":{},"segments":{"0":{"type":"crypt","offset":"16777216","size":"dynamic","iv_tweak":"0","encryption":"`+encryption+`","sector_size":512}}}`)
+	return b
+}
+
+func TestReadLuksHeader(t *testing.T) {
+	h, err := ReadLuksHeader(bytes.NewReader(luks1Header("aes", "cbc-essiv:sha256", "3f6b0a82-8b3e-4f1a-9a07-1b2c3d4e5f60")))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if h.Version != 1 || h.Cipher != "aes-cbc-essiv:sha256" || h.UUID != "3f6b0a82-8b3e-4f1a-9a07-1b2c3d4e5f60" {
+		t.Errorf("Unexpected LUKS1 header %#v", h)
+	}
+
+	h, err = ReadLuksHeader(bytes.NewReader(luks2Header("vault", "9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f", "aes-xts-plain64")))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if h.Version != 2 || h.Cipher != "aes-xts-plain64" || h.UUID != "9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f" || h.Label != "vault" {
+		t.Errorf("Unexpected LUKS2 header %#v", h)
+	}
+
+	if _, err := ReadLuksHeader(bytes.NewReader(make([]byte, 1024))); err != errNotLuks {
+		t.Errorf("Expected errNotLuks, got %v", err)
+	}
+}
+
+func TestLinuxLoaderEncryption(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo":          "28 1 253:1 / / rw,relatime shared:1 - ext4 /dev/mapper/fedora-root rw\n",
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda3/dev":       "8:3\n",
+		"sys/block/sda/sda3/partition": "3\n",
+		"sys/block/sdb/dev":            "8:16\n",
+		"sys/block/sdb/size":           "60437492\n",
+		"sys/block/sdb/sdb1/dev":       "8:17\n",
+		"sys/block/sdb/sdb1/partition": "1\n",
+		"sys/block/sdc/dev":            "8:32\n",
+		"sys/block/sdc/size":           "60437492\n",
+		"sys/block/sdc/sdc1/dev":       "8:33\n",
+		"sys/block/sdc/sdc1/partition": "1\n",
+		"sys/block/dm-0/dev":           "253:0\n",
+		"sys/block/dm-0/size":          "1950351360\n",
+		"sys/block/dm-0/dm/name":       "luks-9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f\n",
+		"sys/block/dm-0/dm/uuid":       "CRYPT-LUKS2-9d1c7e4421aa4c5b8e3faa0b1c2d3e4f-luks-9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f\n",
+		"sys/block/dm-0/slaves/sda3":   "",
+		"sys/block/dm-1/dev":           "253:1\n",
+		"sys/block/dm-1/size":          "67108864\n",
+		"sys/block/dm-1/dm/name":       "fedora-root\n",
+		"sys/block/dm-1/dm/uuid":       "LVM-Xk1bCS9Bs3HoXbl7kX5S0kJWmp3vGYvE7WQnDsJ1gV3vLf1kPxMo8bRa2e5sWbQr\n",
+		"sys/block/dm-1/slaves/dm-0":   "",
+		"dev/sda3":                     string(luks2Header("", "9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f", "aes-xts-plain64")),
+		"dev/sdb1":                     string(luks1Header("aes", "cbc-essiv:sha256", "3f6b0a82-8b3e-4f1a-9a07-1b2c3d4e5f60")),
+		"run/udev/data/b8:33":          "E:ID_FS_TYPE=crypto_LUKS\nE:ID_FS_VERSION=2\nE:ID_FS_UUID=77aa0e1c-5d3b-4c2a-9e8f-0a1b2c3d4e5f\n",
+	})
+
+	res, err := (&LinuxLoader{Roots: FixtureRoots(root), ReadLuksHeaders: true}).Load()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	l := PropertiesList(res)
+
+	e := l.FindByMountPath("/").Encryption
+	if e == nil || e.Type != "LUKS2" || e.Version != 2 || e.Cipher != "aes-xts-plain64" || e.Locked {
+		t.Fatalf("Expected / to be encrypted, got %#v", e)
+	}
+	if e.Device != "/dev/sda3" || e.Mapper != "/dev/mapper/luks-9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f" || e.UUID != "9d1c7e44-21aa-4c5b-8e3f-aa0b1c2d3e4f" {
+		t.Errorf("Unexpected encryption devices %#v", e)
+	}
+	if l.FindByPath("/dev/sda3") != nil || l.FindByPath("/dev/dm-0") != nil {
+		t.Errorf("Expected the opened devices not to be listed")
+	}
+
+	sdb1 := l.FindByPath("/dev/sdb1")
+	if sdb1 == nil || sdb1.Encryption == nil || !sdb1.Encryption.Locked || sdb1.Encryption.Type != "LUKS1" || sdb1.Encryption.Cipher != "aes-cbc-essiv:sha256" || sdb1.MountPath != "" {
+		t.Errorf("Expected sdb1 to be locked, got %#v", sdb1)
+	}
+	sdc1 := l.FindByPath("/dev/sdc1")
+	if sdc1 == nil || sdc1.Encryption == nil || !sdc1.Encryption.Locked || sdc1.Encryption.Type != "LUKS2" || sdc1.Encryption.UUID != "77aa0e1c-5d3b-4c2a-9e8f-0a1b2c3d4e5f" {
+		t.Errorf("Expected sdc1 to be locked, got %#v", sdc1)
+	}
+
+	// without the headers, the devices udev knows are found, without their cipher.
+	res, err = (&LinuxLoader{Roots: FixtureRoots(root)}).Load()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	l = PropertiesList(res)
+	if e := l.FindByMountPath("/").Encryption; e == nil || e.Type != "LUKS2" || e.Device != "/dev/sda3" || e.Cipher != "" {
+		t.Errorf("Expected / to be encrypted without the cipher, got %#v", e)
+	}
+	if sdb1 := l.FindByPath("/dev/sdb1"); sdb1 == nil || sdb1.Encryption != nil {
+		t.Errorf("Expected sdb1, unknown to udev, not to be found encrypted, got %#v", sdb1)
+	}
+	if sdc1 := l.FindByPath("/dev/sdc1"); sdc1 == nil || sdc1.Encryption == nil || !sdc1.Encryption.Locked || sdc1.Encryption.Type != "LUKS2" {
+		t.Errorf("Expected sdc1 to be locked, got %#v", sdc1)
+	}
+}
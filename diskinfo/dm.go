@@ -0,0 +1,111 @@
+package diskinfo
+
+import (
+	"sync"
+)
+
+// deviceName is the canonical path of a block device, and its other paths.
+type deviceName struct {
+	path       string
+	majorMinor string
+	aliases    []string
+}
+
+// deviceNames maps the paths and the major:minor numbers of the block devices to their canonical path.
+// A device-mapper device, such as dm-0, is named /dev/mapper/<name> after /sys/block/dm-0/dm/name,
+// /dev/dm-0 is its alias.
+type deviceNames struct {
+	byPath       map[string]*deviceName
+	byMajorMinor map[string]*deviceName
+}
+
+// readDeviceNames reads the names of the block devices of the sysfs tree.
+func readDeviceNames(roots Roots) (*deviceNames, error) {
+	ret := &deviceNames{
+		byPath:       map[string]*deviceName{},
+		byMajorMinor: map[string]*deviceName{},
+	}
+	disks, err := NewBlockDevicesReader(roots.Path("/sys")).Read()
+	add := func(name, path, majorMinor string) {
+		n := &deviceName{path: path, majorMinor: majorMinor}
+		if alias := devPath(name); alias != path {
+			n.aliases = append(n.aliases, alias)
+			ret.byPath[alias] = n
+		}
+		ret.byPath[path] = n
+		if majorMinor != "" {
+			ret.byMajorMinor[majorMinor] = n
+		}
+	}
+	for _, d := range disks {
+		add(d.Name, d.Path, d.MajorMinor)
+		for _, p := range d.Partitions {
+			add(p.Name, p.Path, p.MajorMinor)
+		}
+	}
+	return ret, err
+}
+
+// loadNames reads the device names once for all the sources of a load.
+type loadNames struct {
+	roots Roots
+	once  sync.Once
+	names *deviceNames
+}
+
+type loadNamesKey struct{}
+
+// get returns the device names, without sysfs the paths are kept as they are.
+func (n *loadNames) get() *deviceNames {
+	n.once.Do(func() {
+		n.names, _ = readDeviceNames(n.roots)
+	})
+	return n.names
+}
+
+// canonicalize replaces the path of p with the canonical path of its device,
+// found by path, or by major:minor, the other paths are kept in its Aliases.
+// An anonymous major:minor, as btrfs reports, does not prevent the match by path.
+func (n *deviceNames) canonicalize(p *Properties) {
+	majorMinor := deviceNumber(p.MajorMinor)
+	d := n.byPath[p.Path]
+	if d == nil && majorMinor != "" {
+		d = n.byMajorMinor[majorMinor]
+	}
+	if d == nil || (majorMinor != "" && majorMinor != d.majorMinor) {
+		return
+	}
+	if p.Path != d.path {
+		p.addAlias(p.Path)
+		p.Path = d.path
+	}
+	for _, a := range d.aliases {
+		p.addAlias(a)
+	}
+	if p.MajorMinor == "" {
+		p.MajorMinor = d.majorMinor
+	}
+}
+
+// addAlias adds path to the aliases of p, once.
+func (p *Properties) addAlias(path string) {
+	for _, a := range p.Aliases {
+		if a == path {
+			return
+		}
+	}
+	p.Aliases = append(p.Aliases, path)
+}
+
+// HasPath tells if p is named path, by its Path or its Aliases.
+func (p *Properties) HasPath(path string) bool {
+	if p.Path == path {
+		return true
+	}
+	for _, a := range p.Aliases {
+		if a == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package diskinfo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeviceNames(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda2/dev":       "8:2\n",
+		"sys/block/sda/sda2/partition": "2\n",
+		"sys/block/dm-0/dev":           "253:0\n",
+		"sys/block/dm-0/size":          "67108864\n",
+		"sys/block/dm-0/dm/name":       "fedora-root\n",
+	})
+	names, err := readDeviceNames(FixtureRoots(root))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	tests := []struct {
+		p    Properties
+		want Properties
+	}{
+		{
+			Properties{Path: "/dev/dm-0", Label: "root"},
+			Properties{Path: "/dev/mapper/fedora-root", Label: "root", MajorMinor: "253:0", Aliases: []string{"/dev/dm-0"}},
+		},
+		{
+			Properties{Path: "/dev/mapper/fedora-root", MajorMinor: "253:0"},
+			Properties{Path: "/dev/mapper/fedora-root", MajorMinor: "253:0", Aliases: []string{"/dev/dm-0"}},
+		},
+		{
+			Properties{Path: "/dev/root", MajorMinor: "8:2"},
+			Properties{Path: "/dev/sda2", MajorMinor: "8:2", Aliases: []string{"/dev/root"}},
+		},
+		{
+			Properties{Path: "/dev/sda2"},
+			Properties{Path: "/dev/sda2", MajorMinor: "8:2"},
+		},
+		{
+			Properties{Path: "tmpfs", MajorMinor: "0:45"},
+			Properties{Path: "tmpfs", MajorMinor: "0:45"},
+		},
+	}
+	for i, test := range tests {
+		p := test.p
+		names.canonicalize(&p)
+		if !reflect.DeepEqual(p, test.want) {
+			t.Errorf("test(%v) Expected %#v, got %#v", i, test.want, p)
+		}
+	}
+
+	l := PropertiesList{&tests[0].want}
+	if l.FindByPath("/dev/dm-0") == nil || l.Index().FindByPath("/dev/dm-0") == nil {
+		t.Errorf("Expected the partition to be found by its alias")
+	}
+}
@@ -0,0 +1,104 @@
+package diskinfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SourceError is the failure of one source of information of a loader.
+type SourceError struct {
+	// Source names the source, such as mountinfo or by-label.
+	Source string
+	// Command is the command line run, or the file read, by the source.
+	Command string
+	// ExitStatus is the exit code of the command, or -1.
+	ExitStatus int
+	// Stderr is the standard error output of the command.
+	Stderr string
+	Err    error
+}
+
+// newSourceError describes the failure err of the source,
+// the command details are taken from CommandError and os.PathError.
+func newSourceError(source string, err error) *SourceError {
+	ret := &SourceError{Source: source, ExitStatus: -1, Err: err}
+	var cmdErr *CommandError
+	var pathErr *os.PathError
+	switch {
+	case errors.As(err, &cmdErr):
+		ret.Command = cmdErr.CommandLine()
+		ret.ExitStatus = cmdErr.ExitStatus()
+		ret.Stderr = cmdErr.Stderr
+	case errors.As(err, &pathErr):
+		ret.Command = pathErr.Path
+	}
+	return ret
+}
+
+// Error returns the source name and its failure.
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Source, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError is returned with the properties a loader could gather
+// when some of its sources failed, or disagreed on a field merged with ErrorOnConflict.
+type LoadError struct {
+	Errors    []*SourceError
+	Conflicts []*ConflictError
+}
+
+// Error lists the failed sources and the conflicts.
+func (e *LoadError) Error() string {
+	var msgs []string
+	for _, s := range e.Errors {
+		msgs = append(msgs, s.Error())
+	}
+	for _, c := range e.Conflicts {
+		msgs = append(msgs, c.Error())
+	}
+	return fmt.Sprintf("%v source(s) failed, %v conflict(s): %v", len(e.Errors), len(e.Conflicts), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed sources, and the conflicts.
+func (e *LoadError) Unwrap() []error {
+	var ret []error
+	for _, s := range e.Errors {
+		ret = append(ret, s)
+	}
+	for _, c := range e.Conflicts {
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// Source returns the error of the named source, or nil.
+func (e *LoadError) Source(name string) *SourceError {
+	for _, s := range e.Errors {
+		if s.Source == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// errOrNil returns e when a source failed.
+func (e *LoadError) errOrNil() error {
+	if len(e.Errors) == 0 && len(e.Conflicts) == 0 {
+		return nil
+	}
+	return e
+}
+
+// IsPartial tells if err is a LoadError, the properties returned with it are
+// those of the sources that succeeded.
+func IsPartial(err error) bool {
+	var loadErr *LoadError
+	return errors.As(err, &loadErr)
+}
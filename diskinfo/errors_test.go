@@ -0,0 +1,57 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPartialResults(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": "28 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw\n",
+		// a file where a directory is expected, as a broken /dev would have.
+		"dev/disk/by-label": "",
+	})
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root)}
+	res, err := loader.Load()
+	if !IsPartial(err) {
+		t.Fatalf("Expected a partial result, got %v", err)
+	}
+	if p := PropertiesList(res).FindByPath("/dev/sda2"); p == nil || p.MountPath != "/" {
+		t.Errorf("Expected the mount points to be loaded, got %v", res)
+	}
+
+	var loadErr *LoadError
+	errors.As(err, &loadErr)
+	if len(loadErr.Errors) != 1 {
+		t.Fatalf("Expected one failed source, got %v", loadErr)
+	}
+	s := loadErr.Source("by-label")
+	if s == nil {
+		t.Fatalf("Expected by-label to fail, got %v", loadErr)
+	}
+	if s.Command != filepath.Join(root, "dev/disk/by-label") || s.ExitStatus != -1 {
+		t.Errorf("Unexpected source error %#v", s)
+	}
+}
+
+func TestSourceErrorCommand(t *testing.T) {
+	_, err := NewReplayRunner("testdata/replay").Run(context.Background(), "vgs", "--reportformat", "json", "--units", "b", "--nosuffix", "-o", "vg_name,vg_uuid,vg_size,vg_free")
+	s := newSourceError("lvm", err)
+	if s.Command != "vgs --reportformat json --units b --nosuffix -o vg_name,vg_uuid,vg_size,vg_free" {
+		t.Errorf("Unexpected command %q", s.Command)
+	}
+	if s.ExitStatus != 1 {
+		t.Errorf("Unexpected exit status %v", s.ExitStatus)
+	}
+	if s.Stderr != "  /run/lock/lvm/P_global:aux: open failed: Permission denied\n" {
+		t.Errorf("Unexpected stderr %q", s.Stderr)
+	}
+	var cmdErr *CommandError
+	if !errors.As(&LoadError{Errors: []*SourceError{s}}, &cmdErr) {
+		t.Errorf("Expected the command error to be unwrapped")
+	}
+}
@@ -0,0 +1,376 @@
+package diskinfo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a property of a partition, such as its Label.
+type Field int
+
+// The fields of Properties.
+const (
+	FieldLabel Field = iota
+	FieldIsRemovable
+	FieldSize
+	FieldSpaceLeft
+	FieldPath
+	FieldMountPath
+	FieldUUID
+	FieldPartUUID
+	FieldPartLabel
+	FieldByID
+	FieldByPath
+	FieldFSType
+	FieldMountOptions
+	FieldReadOnly
+	FieldDescription
+	FieldHealth
+	FieldDisk
+	FieldPartitionNumber
+	FieldPartitionType
+	FieldTimedOut
+	FieldMajorMinor
+	FieldTotalBytes
+	FieldFreeBytes
+	FieldAvailableBytes
+	FieldUsedBytes
+	FieldInodes
+	FieldInodesFree
+	FieldAliases
+	FieldRaid
+	FieldEncryption
+	FieldMounted
+	FieldTransport
+	numFields
+)
+
+// fieldAccessor reads and writes a field of Properties.
+type fieldAccessor struct {
+	name  string
+	empty func(p *Properties) bool
+	equal func(a, b *Properties) bool
+	copy  func(dst, src *Properties)
+	// value is the value of the field, printed in the conflicts.
+	value func(p *Properties) interface{}
+}
+
+func stringField(name string, f func(p *Properties) *string) fieldAccessor {
+	return fieldAccessor{
+		name:  name,
+		empty: func(p *Properties) bool { return *f(p) == "" },
+		equal: func(a, b *Properties) bool { return *f(a) == *f(b) },
+		copy:  func(dst, src *Properties) { *f(dst) = *f(src) },
+		value: func(p *Properties) interface{} { return *f(p) },
+	}
+}
+
+func boolField(name string, f func(p *Properties) *bool) fieldAccessor {
+	return fieldAccessor{
+		name:  name,
+		empty: func(p *Properties) bool { return !*f(p) },
+		equal: func(a, b *Properties) bool { return *f(a) == *f(b) },
+		copy:  func(dst, src *Properties) { *f(dst) = *f(src) },
+		value: func(p *Properties) interface{} { return *f(p) },
+	}
+}
+
+func intField(name string, f func(p *Properties) *int) fieldAccessor {
+	return fieldAccessor{
+		name:  name,
+		empty: func(p *Properties) bool { return *f(p) == 0 },
+		equal: func(a, b *Properties) bool { return *f(a) == *f(b) },
+		copy:  func(dst, src *Properties) { *f(dst) = *f(src) },
+		value: func(p *Properties) interface{} { return *f(p) },
+	}
+}
+
+func uint64Field(name string, f func(p *Properties) *uint64) fieldAccessor {
+	return fieldAccessor{
+		name:  name,
+		empty: func(p *Properties) bool { return *f(p) == 0 },
+		equal: func(a, b *Properties) bool { return *f(a) == *f(b) },
+		copy:  func(dst, src *Properties) { *f(dst) = *f(src) },
+		value: func(p *Properties) interface{} { return *f(p) },
+	}
+}
+
+func byteSizeField(name string, f func(p *Properties) *ByteSize) fieldAccessor {
+	return fieldAccessor{
+		name:  name,
+		empty: func(p *Properties) bool { return *f(p) == 0 },
+		equal: func(a, b *Properties) bool { return *f(a) == *f(b) },
+		copy:  func(dst, src *Properties) { *f(dst) = *f(src) },
+		value: func(p *Properties) interface{} { return *f(p) },
+	}
+}
+
+func stringsField(name string, f func(p *Properties) *[]string) fieldAccessor {
+	return fieldAccessor{
+		name:  name,
+		empty: func(p *Properties) bool { return len(*f(p)) == 0 },
+		equal: func(a, b *Properties) bool { return strings.Join(*f(a), "\x00") == strings.Join(*f(b), "\x00") },
+		copy:  func(dst, src *Properties) { *f(dst) = append([]string(nil), *f(src)...) },
+		value: func(p *Properties) interface{} { return *f(p) },
+	}
+}
+
+var fieldAccessors = [numFields]fieldAccessor{
+	FieldLabel:       stringField("Label", func(p *Properties) *string { return &p.Label }),
+	FieldIsRemovable: boolField("IsRemovable", func(p *Properties) *bool { return &p.IsRemovable }),
+	FieldSize:        stringField("Size", func(p *Properties) *string { return &p.Size }),
+	FieldSpaceLeft:   stringField("SpaceLeft", func(p *Properties) *string { return &p.SpaceLeft }),
+	FieldPath:        stringField("Path", func(p *Properties) *string { return &p.Path }),
+	FieldMountPath:   stringField("MountPath", func(p *Properties) *string { return &p.MountPath }),
+	FieldUUID:        stringField("UUID", func(p *Properties) *string { return &p.UUID }),
+	FieldPartUUID:    stringField("PartUUID", func(p *Properties) *string { return &p.PartUUID }),
+	FieldPartLabel:   stringField("PartLabel", func(p *Properties) *string { return &p.PartLabel }),
+	FieldByID:        stringsField("ByID", func(p *Properties) *[]string { return &p.ByID }),
+	FieldByPath:      stringsField("ByPath", func(p *Properties) *[]string { return &p.ByPath }),
+	FieldFSType:      stringField("FSType", func(p *Properties) *string { return &p.FSType }),
+	FieldMountOptions: {
+		name:  "MountOptions",
+		empty: func(p *Properties) bool { return len(p.MountOptions) == 0 },
+		equal: func(a, b *Properties) bool { return a.MountOptions.String() == b.MountOptions.String() },
+		copy: func(dst, src *Properties) {
+			dst.MountOptions = MountOptions{}
+			for k, v := range src.MountOptions {
+				dst.MountOptions[k] = v
+			}
+		},
+		value: func(p *Properties) interface{} { return p.MountOptions.String() },
+	},
+	FieldReadOnly:        boolField("ReadOnly", func(p *Properties) *bool { return &p.ReadOnly }),
+	FieldDescription:     stringField("Description", func(p *Properties) *string { return &p.Description }),
+	FieldHealth:          stringField("Health", func(p *Properties) *string { return &p.Health }),
+	FieldDisk:            stringField("Disk", func(p *Properties) *string { return &p.Disk }),
+	FieldPartitionNumber: intField("PartitionNumber", func(p *Properties) *int { return &p.PartitionNumber }),
+	FieldPartitionType:   stringField("PartitionType", func(p *Properties) *string { return &p.PartitionType }),
+	FieldTimedOut:        boolField("TimedOut", func(p *Properties) *bool { return &p.TimedOut }),
+	FieldMajorMinor:      stringField("MajorMinor", func(p *Properties) *string { return &p.MajorMinor }),
+	FieldTotalBytes:      byteSizeField("TotalBytes", func(p *Properties) *ByteSize { return &p.TotalBytes }),
+	FieldFreeBytes:       byteSizeField("FreeBytes", func(p *Properties) *ByteSize { return &p.FreeBytes }),
+	FieldAvailableBytes:  byteSizeField("AvailableBytes", func(p *Properties) *ByteSize { return &p.AvailableBytes }),
+	FieldUsedBytes:       byteSizeField("UsedBytes", func(p *Properties) *ByteSize { return &p.UsedBytes }),
+	FieldInodes:          uint64Field("Inodes", func(p *Properties) *uint64 { return &p.Inodes }),
+	FieldInodesFree:      uint64Field("InodesFree", func(p *Properties) *uint64 { return &p.InodesFree }),
+	FieldAliases:         stringsField("Aliases", func(p *Properties) *[]string { return &p.Aliases }),
+	FieldRaid: {
+		name:  "Raid",
+		empty: func(p *Properties) bool { return p.Raid == nil },
+		equal: func(a, b *Properties) bool { return a.Raid == b.Raid },
+		copy:  func(dst, src *Properties) { dst.Raid = src.Raid },
+		value: func(p *Properties) interface{} { return p.Raid.Path },
+	},
+	FieldEncryption: {
+		name:  "Encryption",
+		empty: func(p *Properties) bool { return p.Encryption == nil },
+		equal: func(a, b *Properties) bool { return a.Encryption == b.Encryption },
+		copy:  func(dst, src *Properties) { dst.Encryption = src.Encryption },
+		value: func(p *Properties) interface{} { return p.Encryption.Type + " " + p.Encryption.UUID },
+	},
+	FieldMounted:   boolField("Mounted", func(p *Properties) *bool { return &p.Mounted }),
+	FieldTransport: stringField("Transport", func(p *Properties) *string { return &p.Transport }),
+}
+
+func (f Field) valid() bool {
+	return f >= 0 && f < numFields
+}
+
+// String returns the name of the field in Properties.
+func (f Field) String() string {
+	if !f.valid() {
+		return fmt.Sprintf("Field(%d)", int(f))
+	}
+	return fieldAccessors[f].name
+}
+
+// MarshalText writes the name of the field, it makes a Field usable as a JSON key.
+func (f Field) MarshalText() ([]byte, error) {
+	if !f.valid() {
+		return nil, fmt.Errorf("invalid field %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText reads the name of a field.
+func (f *Field) UnmarshalText(b []byte) error {
+	v, err := ParseField(string(b))
+	if err != nil {
+		return err
+	}
+	*f = v
+	return nil
+}
+
+// ParseField returns the field of Properties named s, such as Label.
+func ParseField(s string) (Field, error) {
+	for f := Field(0); f < numFields; f++ {
+		if fieldAccessors[f].name == s {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown field %q", s)
+}
+
+// IsEmpty tells if the field of p has its zero value.
+func (f Field) IsEmpty(p *Properties) bool {
+	return fieldAccessors[f].empty(p)
+}
+
+// FieldSet is a set of fields.
+type FieldSet uint64
+
+// NewFieldSet returns the set of fields.
+func NewFieldSet(fields ...Field) FieldSet {
+	var ret FieldSet
+	for _, f := range fields {
+		ret = ret.Add(f)
+	}
+	return ret
+}
+
+// AllFields is the set of every field.
+const AllFields = FieldSet(1<<uint(numFields) - 1)
+
+// Add returns the set with f.
+func (s FieldSet) Add(f Field) FieldSet {
+	return s | 1<<uint(f)
+}
+
+// Has tells if f is in the set.
+func (s FieldSet) Has(f Field) bool {
+	return f.valid() && s&(1<<uint(f)) != 0
+}
+
+// Fields returns the fields of the set, in the order of Properties.
+func (s FieldSet) Fields() []Field {
+	var ret []Field
+	for f := Field(0); f < numFields; f++ {
+		if s.Has(f) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// String returns the comma separated names of the fields.
+func (s FieldSet) String() string {
+	var ret []string
+	for _, f := range s.Fields() {
+		ret = append(ret, f.String())
+	}
+	return strings.Join(ret, ",")
+}
+
+// MergePolicy tells how a field is merged when both partitions have a value.
+type MergePolicy int
+
+// The merge policies.
+const (
+	// PreferNonEmpty takes the merged value, unless it is empty.
+	PreferNonEmpty MergePolicy = iota
+	// FirstWins keeps the existing value, the merged value only fills empty fields.
+	FirstWins
+	// LastWins always takes the merged value, even empty.
+	LastWins
+	// ErrorOnConflict fills empty fields, and reports a ConflictError when the values differ.
+	// The existing value is kept.
+	ErrorOnConflict
+)
+
+var mergePolicyNames = []string{"PreferNonEmpty", "FirstWins", "LastWins", "ErrorOnConflict"}
+
+// String returns the name of the policy.
+func (m MergePolicy) String() string {
+	if m < 0 || int(m) >= len(mergePolicyNames) {
+		return fmt.Sprintf("MergePolicy(%d)", int(m))
+	}
+	return mergePolicyNames[m]
+}
+
+// MarshalText marshals the policy as its name.
+func (m MergePolicy) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// ConflictError is a field two sources gave different values.
+// It is an error with ErrorOnConflict, with the other policies it records the value discarded.
+type ConflictError struct {
+	// Path identifies the partition.
+	Path  string
+	Field Field
+	// Kept is the source of the value kept, Rejected the source of the other value.
+	Kept     string
+	Rejected string
+	// KeptValue and RejectedValue are the values, as text.
+	KeptValue     string
+	RejectedValue string
+	// Policy is the merge policy that chose the value kept.
+	Policy MergePolicy
+}
+
+// Error describes the conflict.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: conflicting %v, kept %q from %q, rejected %q from %q",
+		e.Path, e.Field, e.KeptValue, e.Kept, e.RejectedValue, e.Rejected)
+}
+
+// mergeField merges the field f of src into dst, according to policy,
+// the provenance of the field follows its value.
+// When both have a different value, the conflict is recorded in the Conflicts of dst, and returned.
+func mergeField(dst, src *Properties, f Field, policy MergePolicy) *ConflictError {
+	a := fieldAccessors[f]
+	var c *ConflictError
+	if !a.empty(dst) && !a.empty(src) && !a.equal(dst, src) {
+		c = &ConflictError{Path: dst.Path, Field: f, Policy: policy}
+	}
+	take := false
+	switch policy {
+	case PreferNonEmpty:
+		take = !a.empty(src)
+	case FirstWins, ErrorOnConflict:
+		take = a.empty(dst) && !a.empty(src)
+	case LastWins:
+		take = true
+	}
+	if c != nil {
+		kept, rejected := dst, src
+		if take {
+			kept, rejected = src, dst
+		}
+		c.Kept, c.KeptValue = kept.SourceOf(f), fmt.Sprint(a.value(kept))
+		c.Rejected, c.RejectedValue = rejected.SourceOf(f), fmt.Sprint(a.value(rejected))
+		dst.Conflicts = append(dst.Conflicts, c)
+	}
+	if take {
+		a.copy(dst, src)
+		dst.setSource(f, src.SourceOf(f))
+	}
+	return c
+}
+
+// SourceOf returns the name of the source that supplied the field f, or an empty string.
+func (p *Properties) SourceOf(f Field) string {
+	return p.Sources[f]
+}
+
+func (p *Properties) setSource(f Field, source string) {
+	if source == "" {
+		delete(p.Sources, f)
+		return
+	}
+	if p.Sources == nil {
+		p.Sources = map[Field]string{}
+	}
+	p.Sources[f] = source
+}
+
+// stampSource records source as the provenance of the non empty fields of p.
+func stampSource(p *Properties, source string) {
+	for f := Field(0); f < numFields; f++ {
+		if !fieldAccessors[f].empty(p) {
+			p.setSource(f, source)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package diskinfo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergePolicies(t *testing.T) {
+	tests := []struct {
+		policy   MergePolicy
+		dst, src string
+		want     string
+		source   string
+		// rejected is the source of the value discarded, when the values conflict.
+		rejected string
+	}{
+		{PreferNonEmpty, "data", "backup", "backup", "cmdb", "by-label"},
+		{PreferNonEmpty, "data", "", "data", "by-label", ""},
+		{FirstWins, "data", "backup", "data", "by-label", "cmdb"},
+		{FirstWins, "", "backup", "backup", "cmdb", ""},
+		{LastWins, "data", "", "", "", ""},
+		{LastWins, "data", "backup", "backup", "cmdb", "by-label"},
+		{ErrorOnConflict, "data", "backup", "data", "by-label", "cmdb"},
+		{ErrorOnConflict, "data", "data", "data", "by-label", ""},
+		{ErrorOnConflict, "", "backup", "backup", "cmdb", ""},
+	}
+	for i, test := range tests {
+		dst := &Properties{Path: "/dev/sdb1", Label: test.dst}
+		stampSource(dst, "by-label")
+		src := &Properties{Path: "/dev/sdb1", Label: test.src}
+		stampSource(src, "cmdb")
+
+		res, conflicts := PropertiesList{dst}.MergeFields(PropertiesList{src}, NewFieldSet(FieldLabel), test.policy)
+		if res[0].Label != test.want {
+			t.Errorf("test(%v) Expected Label=%q, got %q", i, test.want, res[0].Label)
+		}
+		if got := res[0].SourceOf(FieldLabel); got != test.source {
+			t.Errorf("test(%v) Expected Label from %q, got %q", i, test.source, got)
+		}
+		if test.rejected == "" {
+			if len(conflicts) != 0 || len(res[0].Conflicts) != 0 {
+				t.Errorf("test(%v) Expected no conflict, got %v", i, conflicts)
+			}
+			continue
+		}
+		if len(conflicts) != 1 || len(res[0].Conflicts) != 1 || res[0].Conflicts[0] != conflicts[0] {
+			t.Fatalf("test(%v) Expected a conflict recorded in the partition, got %v", i, conflicts)
+		}
+		c := conflicts[0]
+		rejectedValue := test.dst
+		if test.rejected == "cmdb" {
+			rejectedValue = test.src
+		}
+		if c.Kept != test.source || c.KeptValue != test.want || c.Rejected != test.rejected || c.RejectedValue != rejectedValue || c.Policy != test.policy {
+			t.Errorf("test(%v) Unexpected conflict %#v", i, c)
+		}
+	}
+}
+
+func TestMergeRemovable(t *testing.T) {
+	dst := PropertiesList{{Path: "/dev/sdb1", IsRemovable: true}}
+	src := PropertiesList{{Path: "/dev/sdb1", IsRemovable: false}}
+
+	res, _ := dst.MergeFields(src, NewFieldSet(FieldIsRemovable), PreferNonEmpty)
+	if !res[0].IsRemovable {
+		t.Errorf("Expected IsRemovable to be kept")
+	}
+	res = PropertiesList(res).Merge(src, "IsRemovable", "Lable")
+	if res[0].IsRemovable {
+		t.Errorf("Expected IsRemovable to be overwritten")
+	}
+}
+
+func TestFieldSet(t *testing.T) {
+	s := NewFieldSet(FieldUUID, FieldLabel, FieldInodesFree)
+	if s.String() != "Label,UUID,InodesFree" {
+		t.Errorf("Unexpected field set %v", s)
+	}
+	if s.Has(FieldPath) || !s.Has(FieldUUID) || !AllFields.Has(FieldInodesFree) {
+		t.Errorf("Unexpected Has result")
+	}
+	if f, err := ParseField("PartUUID"); err != nil || f != FieldPartUUID {
+		t.Errorf("Unexpected ParseField result %v %v", f, err)
+	}
+	if _, err := ParseField("Lable"); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
+
+func TestCompositeLoaderConflicts(t *testing.T) {
+	c := NewCompositeLoader()
+	c.Register(SourceInfo{Name: "mounts", Adds: AllPartitions}, staticSource(
+		&Properties{Path: "/dev/sda1", MountPath: "/srv", Label: "data"},
+	))
+	c.Register(SourceInfo{Name: "cmdb", Priority: 10, Fields: NewFieldSet(FieldLabel, FieldDescription), Policy: ErrorOnConflict}, staticSource(
+		&Properties{Path: "/dev/sda1", Label: "db-data", Description: "postgres"},
+	))
+
+	res, err := c.Load()
+	loadErr, ok := err.(*LoadError)
+	if !ok || len(loadErr.Errors) != 0 || len(loadErr.Conflicts) != 1 {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	if c := loadErr.Conflicts[0]; c.Field != FieldLabel || c.Kept != "mounts" || c.Rejected != "cmdb" {
+		t.Errorf("Unexpected conflict %v", c)
+	}
+	p := res[0]
+	if p.Label != "data" || p.Description != "postgres" {
+		t.Errorf("Unexpected properties %#v", p)
+	}
+
+	b, err := json.Marshal(p.Sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Description":"cmdb","Label":"mounts","MountPath":"mounts","Path":"mounts"}` {
+		t.Errorf("Unexpected provenance %s", b)
+	}
+}
+
+func TestCompositeLoaderResolvedConflicts(t *testing.T) {
+	c := NewCompositeLoader()
+	c.Register(SourceInfo{Name: "mounts", Adds: AllPartitions}, staticSource(
+		&Properties{Path: "/dev/sda1", MountPath: "/srv", Label: "data"},
+	))
+	c.Register(SourceInfo{Name: "udev", Priority: 10, Fields: NewFieldSet(FieldLabel)}, staticSource(
+		&Properties{Path: "/dev/sda1", Label: "db-data"},
+	))
+
+	res, err := c.Load()
+	if err != nil {
+		t.Fatalf("Expected the conflict not to be an error, got %v", err)
+	}
+	p := res[0]
+	if p.Label != "db-data" || len(p.Conflicts) != 1 {
+		t.Fatalf("Expected the conflict to be recorded, got %#v", p)
+	}
+	b, err := json.Marshal(p.Conflicts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"Path":"/dev/sda1","Field":"Label","Kept":"udev","Rejected":"mounts","KeptValue":"db-data","RejectedValue":"data","Policy":"PreferNonEmpty"}]`
+	if string(b) != want {
+		t.Errorf("Unexpected conflicts %s", b)
+	}
+}
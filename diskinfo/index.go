@@ -0,0 +1,181 @@
+package diskinfo
+
+import (
+	"strings"
+)
+
+// PropertiesIndex is a list of partitions indexed by path, mount path, UUID, label and major:minor,
+// its lookups do not depend on the size of the list.
+type PropertiesIndex struct {
+	list         PropertiesList
+	byPath       map[string][]*Properties
+	byMountPath  map[string]*Properties
+	byUUID       map[string]*Properties
+	byLabel      map[string]*Properties
+	byMajorMinor map[string][]*Properties
+}
+
+// NewPropertiesIndex indexes the partitions of l.
+func NewPropertiesIndex(l []*Properties) *PropertiesIndex {
+	x := &PropertiesIndex{
+		byPath:       map[string][]*Properties{},
+		byMountPath:  map[string]*Properties{},
+		byUUID:       map[string]*Properties{},
+		byLabel:      map[string]*Properties{},
+		byMajorMinor: map[string][]*Properties{},
+	}
+	for _, p := range l {
+		x.Add(p)
+	}
+	return x
+}
+
+// Index indexes the partitions of the list.
+func (l PropertiesList) Index() *PropertiesIndex {
+	return NewPropertiesIndex(l)
+}
+
+// Add appends p to the list, and indexes it.
+// The lookups return the first partition added with a value,
+// but for the mount path where the last one added hides the previous ones.
+// The index of a partition modified after it was added is not updated.
+func (x *PropertiesIndex) Add(p *Properties) {
+	x.list = append(x.list, p)
+	x.byPath[p.Path] = append(x.byPath[p.Path], p)
+	for _, a := range p.Aliases {
+		x.byPath[a] = append(x.byPath[a], p)
+	}
+	if p.MountPath != "" {
+		x.byMountPath[p.MountPath] = p
+	}
+	addOnce(x.byUUID, p.UUID, p)
+	addOnce(x.byLabel, p.Label, p)
+	if p.MajorMinor != "" {
+		x.byMajorMinor[p.MajorMinor] = append(x.byMajorMinor[p.MajorMinor], p)
+	}
+}
+
+func addOnce(m map[string]*Properties, key string, p *Properties) {
+	if _, ok := m[key]; key != "" && !ok {
+		m[key] = p
+	}
+}
+
+// List returns the indexed partitions, in the order they were added.
+func (x *PropertiesIndex) List() PropertiesList {
+	return x.list
+}
+
+// FindByPath search a partition by its device path, or one of its aliases.
+func (x *PropertiesIndex) FindByPath(path string) *Properties {
+	if l := x.byPath[path]; len(l) > 0 {
+		return l[0]
+	}
+	return nil
+}
+
+// FindByMountPath search a partition by its mount path.
+func (x *PropertiesIndex) FindByMountPath(mountPath string) *Properties {
+	return x.byMountPath[mountPath]
+}
+
+// FindByUUID search a partition by its filesystem UUID.
+func (x *PropertiesIndex) FindByUUID(uuid string) *Properties {
+	return x.byUUID[uuid]
+}
+
+// FindByLabel search a partition by its label.
+func (x *PropertiesIndex) FindByLabel(label string) *Properties {
+	return x.byLabel[label]
+}
+
+// FindByMajorMinor search a partition by its device number, such as 8:1.
+func (x *PropertiesIndex) FindByMajorMinor(majorMinor string) *Properties {
+	if l := x.byMajorMinor[majorMinor]; len(l) > 0 {
+		return l[0]
+	}
+	return nil
+}
+
+// FindContaining returns the mounted partition holding the file at filePath,
+// the one with the longest mount path containing it.
+// filePath must be absolute and clean, symbolic links are not resolved.
+// Both slashes and backslashes separate the directories, for C:\Users to be found on C:.
+func (x *PropertiesIndex) FindContaining(filePath string) *Properties {
+	dir := filePath
+	for {
+		if p := x.byMountPath[dir]; p != nil {
+			return p
+		}
+		i := strings.LastIndexAny(dir, `/\`)
+		if i < 0 {
+			return nil
+		}
+		if i < len(dir)-1 {
+			// the parent is looked up with its trailing separator, for the roots / and C:\,
+			// then without.
+			dir = dir[:i+1]
+			continue
+		}
+		dir = dir[:i]
+		if dir == "" {
+			return nil
+		}
+	}
+}
+
+// find search the partition matching p.
+// Partitions are matched by their MajorMinor value when both have one, by their Path value otherwise.
+// The mounts of a same device, such as a bind mount or the subvolumes of a btrfs filesystem,
+// are distinct partitions told apart by their MountPath.
+func (x *PropertiesIndex) find(p *Properties) *Properties {
+	var candidates []*Properties
+	if p.MajorMinor != "" {
+		candidates = append(candidates, x.byMajorMinor[p.MajorMinor]...)
+	}
+	if isDevPath(p.Path) || deviceNumber(p.MajorMinor) == p.MajorMinor {
+		for _, d := range x.byPath[p.Path] {
+			if matchByPath(p, d) {
+				candidates = append(candidates, d)
+			}
+		}
+	}
+	if d := x.byMountPath[p.MountPath]; d != nil && !isDevPath(p.Path) && d.Path == p.Path {
+		// a mount without a block device, such as a tmpfs, is named by its mount point.
+		candidates = append(candidates, d)
+	}
+	for _, d := range candidates {
+		if p.MountPath == "" || d.MountPath == "" || p.MountPath == d.MountPath {
+			return d
+		}
+	}
+	return nil
+}
+
+// matchByPath tells if p and d, of the same Path, are the same partition.
+// Two device numbers are compared rather than the paths. The major 0 numbers are anonymous,
+// such as the ones btrfs reports in mountinfo for the filesystem on /dev/sda3, 8:3,
+// the path only names the partition when it is a /dev block device: all the tmpfs mounts are named tmpfs.
+func matchByPath(p, d *Properties) bool {
+	pn, dn := deviceNumber(p.MajorMinor), deviceNumber(d.MajorMinor)
+	if pn != "" && dn != "" {
+		return false
+	}
+	if pn != p.MajorMinor || dn != d.MajorMinor {
+		return isDevPath(p.Path)
+	}
+	return true
+}
+
+// deviceNumber returns majorMinor when it identifies a block device, or an empty string.
+func deviceNumber(majorMinor string) string {
+	if strings.HasPrefix(majorMinor, "0:") {
+		return ""
+	}
+	return majorMinor
+}
+
+// isDevPath tells if path is a block device path, such as /dev/sda1.
+func isDevPath(path string) bool {
+	return strings.HasPrefix(path, "/dev/")
+}
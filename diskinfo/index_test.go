@@ -0,0 +1,174 @@
+package diskinfo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPropertiesIndex(t *testing.T) {
+	x := NewPropertiesIndex([]*Properties{
+		{Path: "/dev/sda2", MountPath: "/", MajorMinor: "8:2", UUID: "6a3c8f2e", Label: "root"},
+		{Path: "/dev/sda3", MountPath: "/srv", MajorMinor: "8:3", Label: "stockage"},
+		{Path: "tmpfs", MountPath: "/srv/cache", MajorMinor: "0:45"},
+		{Path: "tmpfs", MountPath: "/run", MajorMinor: "0:24"},
+		{Path: "/dev/sdb1", MountPath: "/srv/cache", MajorMinor: "8:17", Label: "stockage"},
+		{Path: "/dev/sdc1", MajorMinor: "8:33", UUID: "2C1E-7F0A"},
+		{Path: "C:", MountPath: "C:"},
+		{Path: "D:", MountPath: `D:\`},
+	})
+
+	if p := x.FindByPath("tmpfs"); p == nil || p.MountPath != "/srv/cache" {
+		t.Errorf("Expected the first tmpfs, got %v", p)
+	}
+	if p := x.FindByMountPath("/srv/cache"); p == nil || p.Path != "/dev/sdb1" {
+		t.Errorf("Expected the last partition mounted on /srv/cache, got %v", p)
+	}
+	if p := x.FindByUUID("2C1E-7F0A"); p == nil || p.Path != "/dev/sdc1" {
+		t.Errorf("Unexpected partition %v", p)
+	}
+	if p := x.FindByLabel("stockage"); p == nil || p.Path != "/dev/sda3" {
+		t.Errorf("Expected the first partition labelled stockage, got %v", p)
+	}
+	if p := x.FindByMajorMinor("0:24"); p == nil || p.MountPath != "/run" {
+		t.Errorf("Unexpected partition %v", p)
+	}
+	if p := x.FindByMajorMinor("8:99"); p != nil {
+		t.Errorf("Unexpected partition %v", p)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"/", "/"},
+		{"/etc/fstab", "/"},
+		{"/srv", "/srv"},
+		{"/srv/", "/srv"},
+		{"/srv/www/index.html", "/srv"},
+		{"/srvx/file", "/"},
+		{"/srv/cache/a/b", "/srv/cache"},
+		{"/run/user/1000", "/run"},
+		{`C:\Users\me\file.txt`, "C:"},
+		{`C:`, "C:"},
+		{`D:\data`, `D:\`},
+		{`E:\data`, ""},
+		{"relative/path", ""},
+	}
+	for _, test := range tests {
+		got := ""
+		if p := x.FindContaining(test.file); p != nil {
+			got = p.MountPath
+		}
+		if got != test.want {
+			t.Errorf("FindContaining(%q) Expected %q, got %q", test.file, test.want, got)
+		}
+	}
+}
+
+func TestPropertiesListFindContaining(t *testing.T) {
+	l := PropertiesList{
+		{Path: "/dev/sda2", MountPath: "/"},
+		{Path: "/dev/sda3", MountPath: "/home"},
+	}
+	if p := l.FindContaining("/home/me/.bashrc"); p == nil || p.Path != "/dev/sda3" {
+		t.Errorf("Unexpected partition %v", p)
+	}
+	if p := l.FindByMountPath("/"); p == nil || p.Path != "/dev/sda2" {
+		t.Errorf("Unexpected partition %v", p)
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	var mounts, labels PropertiesList
+	for i := 0; i < 5000; i++ {
+		mounts = append(mounts, &Properties{Path: "overlay", MountPath: fmt.Sprintf("/var/lib/kubelet/pods/%v", i), MajorMinor: fmt.Sprintf("0:%v", i+100)})
+		labels = append(labels, &Properties{Path: fmt.Sprintf("/dev/loop%v", i), Label: "snap"})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := append(PropertiesList(nil), mounts...)
+		l = l.Append(labels)
+		l.MergeFields(labels, NewFieldSet(FieldLabel), PreferNonEmpty)
+	}
+}
+
+func TestLinuxLoaderAnonymousMounts(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": `28 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+30 28 0:27 / /dev/shm rw,nosuid,nodev shared:3 - tmpfs tmpfs rw,seclabel
+31 28 0:52 / /run/user/1000 rw,nosuid,nodev,relatime shared:40 - tmpfs tmpfs rw,seclabel,size=1620960k,mode=700,uid=1000,gid=1000
+40 28 0:60 / /var/lib/docker/overlay2/a/merged rw,relatime - overlay overlay rw,lowerdir=/l1,upperdir=/u1,workdir=/w1
+41 28 0:61 / /var/lib/docker/overlay2/b/merged rw,relatime - overlay overlay rw,lowerdir=/l2,upperdir=/u2,workdir=/w2
+`,
+	})
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root), statfs: fixtureStatfs}
+	res, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	l := PropertiesList(res)
+	if len(l) != 5 {
+		t.Fatalf("Expected every mount to be listed, got %v", len(l))
+	}
+	for _, mountPath := range []string{"/dev/shm", "/run/user/1000", "/var/lib/docker/overlay2/a/merged", "/var/lib/docker/overlay2/b/merged"} {
+		if p := l.FindByMountPath(mountPath); p == nil || p.MountPath != mountPath {
+			t.Errorf("Expected the mount %v, got %#v", mountPath, p)
+		}
+	}
+	if p := l.FindContaining("/run/user/1000/file"); p == nil || p.MajorMinor != "0:52" {
+		t.Errorf("Unexpected containing mount %#v", p)
+	}
+}
+
+// fixtureStatfs gives the same usage to every mount point.
+func fixtureStatfs(path string) (fsUsage, error) {
+	return fsUsage{Total: 8 * uint64(GiB), Free: 4 * uint64(GiB), Available: 4 * uint64(GiB)}, nil
+}
+
+func TestLinuxLoaderBindMounts(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": `28 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+35 28 8:1 /srv/data /mnt/bind rw,relatime shared:1 - ext4 /dev/sda1 rw
+40 28 0:60 / /var/lib/docker/overlay2/a/merged rw,relatime - overlay overlay rw,lowerdir=/l1,upperdir=/u1,workdir=/w1
+41 28 0:61 / /var/lib/docker/overlay2/b/merged rw,relatime - overlay overlay rw,lowerdir=/l2,upperdir=/u2,workdir=/w2
+`,
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda1/dev":       "8:1\n",
+		"sys/block/sda/sda1/partition": "1\n",
+		"sys/block/sda/sda1/size":      "1953521664\n",
+		"run/udev/data/b8:1":           "E:ID_FS_TYPE=ext4\nE:ID_FS_LABEL=root\n",
+	})
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root), statfs: fixtureStatfs}
+	res, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	x := PropertiesList(res).Index()
+	if len(x.List()) != 4 {
+		t.Fatalf("Expected every mount to be listed, got %v", len(x.List()))
+	}
+	for _, mountPath := range []string{"/", "/mnt/bind"} {
+		if p := x.FindByMountPath(mountPath); p == nil || p.Path != "/dev/sda1" || p.Label != "root" || p.PartitionNumber != 1 {
+			t.Errorf("Unexpected mount %v %#v", mountPath, p)
+		}
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"/mnt/bind/file", "/mnt/bind"},
+		{"/var/lib/docker/overlay2/b/merged/etc/hosts", "/var/lib/docker/overlay2/b/merged"},
+		{"/var/lib/docker/overlay2/a/merged/etc/hosts", "/var/lib/docker/overlay2/a/merged"},
+		{"/var/lib/docker/overlay2/c", "/"},
+	}
+	for _, test := range tests {
+		if p := x.FindContaining(test.file); p == nil || p.MountPath != test.want {
+			t.Errorf("FindContaining(%q) Expected %q, got %#v", test.file, test.want, p)
+		}
+	}
+}
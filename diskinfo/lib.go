@@ -1,11 +1,16 @@
 // Package diskinfo provides the list of partitions
 package diskinfo
 
-import "runtime"
+import (
+	"context"
+	"runtime"
+)
 
 // PropertiesLoader can load the list of partitions and their properties.
 type PropertiesLoader interface {
 	Load() ([]*Properties, error)
+	// LoadContext loads the partitions until ctx is done.
+	LoadContext(ctx context.Context) ([]*Properties, error)
 }
 
 // NewMultiOsLoader prepares a multi os loader for the current runtime operating system.
@@ -19,6 +24,9 @@ func NewMultiOsLoader() PropertiesLoader {
 }
 
 // Properties provides information about a partition on the system.
+// Size and SpaceLeft are human readable strings kept for compatibility,
+// the exact values are provided by the ByteSize fields.
+// The sizes of a partition that is not mounted are the size of the partition.
 type Properties struct {
 	Label       string
 	IsRemovable bool
@@ -26,6 +34,65 @@ type Properties struct {
 	SpaceLeft   string
 	Path        string
 	MountPath   string
+	// Mounted tells the filesystem is mounted at MountPath,
+	// the partitions that are not are listed too.
+	Mounted bool
+	// UUID is the filesystem UUID, as in /dev/disk/by-uuid.
+	UUID string `json:",omitempty"`
+	// PartUUID is the partition UUID of the partition table, as in /dev/disk/by-partuuid.
+	PartUUID string `json:",omitempty"`
+	// PartLabel is the partition name of the partition table, as in /dev/disk/by-partlabel.
+	PartLabel string `json:",omitempty"`
+	// ByID are the names of the partition in /dev/disk/by-id.
+	ByID []string `json:",omitempty"`
+	// ByPath are the names of the partition in /dev/disk/by-path.
+	ByPath []string `json:",omitempty"`
+	// FSType is the type of the filesystem, such as ext4 or NTFS.
+	FSType string `json:",omitempty"`
+	// MountOptions are the options of the mount point, merged with the super block options.
+	MountOptions MountOptions `json:",omitempty"`
+	// ReadOnly tells if the filesystem is mounted read-only,
+	// such as after an ext4 errors=remount-ro.
+	ReadOnly bool `json:",omitempty"`
+	// Description is the kind of drive reported by the system, such as Local Fixed Disk.
+	Description string `json:",omitempty"`
+	// Health is the health status reported by the system, such as Healthy.
+	Health string `json:",omitempty"`
+	// Disk is the path of the physical disk holding the partition.
+	Disk string `json:",omitempty"`
+	// Transport is the hot-pluggable bus of the disk, usb, mmc, ieee1394 or thunderbolt.
+	Transport string `json:",omitempty"`
+	// PartitionNumber is the number of the partition on its disk, starting at 1.
+	PartitionNumber int `json:",omitempty"`
+	// PartitionType is the GPT type GUID, or the 0xNN MBR type, of the partition.
+	PartitionType string `json:",omitempty"`
+	// TimedOut tells the filesystem did not answer in time, such as a hung NFS mount,
+	// its usage is unknown.
+	TimedOut bool `json:",omitempty"`
+	// MajorMinor is the device number of the mounted filesystem, such as 8:1.
+	MajorMinor string `json:",omitempty"`
+	// TotalBytes is the size of the filesystem.
+	TotalBytes ByteSize `json:",omitempty"`
+	// FreeBytes is the free space of the filesystem, including the reserved blocks.
+	FreeBytes ByteSize `json:",omitempty"`
+	// AvailableBytes is the free space available to unprivileged users.
+	AvailableBytes ByteSize `json:",omitempty"`
+	// UsedBytes is the space in use.
+	UsedBytes ByteSize `json:",omitempty"`
+	// Inodes is the total number of inodes of the filesystem.
+	Inodes uint64 `json:",omitempty"`
+	// InodesFree is the number of free inodes of the filesystem.
+	InodesFree uint64 `json:",omitempty"`
+	// Aliases are the other paths of the device, such as /dev/dm-0 for /dev/mapper/fedora-root.
+	Aliases []string `json:",omitempty"`
+	// Raid is the software RAID array of a md device.
+	Raid *RaidArray `json:",omitempty"`
+	// Encryption is the dm-crypt encryption of the volume, or of the devices it is stacked on.
+	Encryption *Encryption `json:",omitempty"`
+	// Sources are the names of the sources that supplied each field, such as mountinfo or udev.
+	Sources map[Field]string `json:",omitempty"`
+	// Conflicts are the fields the sources gave different values, with the value discarded.
+	Conflicts []*ConflictError `json:",omitempty"`
 }
 
 // NewProperties is a constructor.
@@ -36,61 +103,85 @@ func NewProperties() *Properties {
 // PropertiesList is type alias to []*Properties
 type PropertiesList []*Properties
 
+// MergeFields merges the fields of some into the matching partitions of this list, according to policy.
+// Partitions are matched by their MajorMinor value when both have one, by their Path value otherwise.
+// It returns the conflicts, the fields both partitions had a different value, whatever the policy.
+func (l PropertiesList) MergeFields(some PropertiesList, fields FieldSet, policy MergePolicy) ([]*Properties, []*ConflictError) {
+	var conflicts []*ConflictError
+	index := some.Index()
+	for _, d := range l {
+		s := index.find(d)
+		if s == nil {
+			continue
+		}
+		for _, f := range fields.Fields() {
+			if c := mergeField(d, s, f, policy); c != nil {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+	return l, conflicts
+}
+
 // Merge some []*Properties into this list. what is a property name of Properties.
-// Partitions are matched by their Path value.
+// Partitions are matched by their MajorMinor value when both have one, by their Path value otherwise.
+// Empty values are not merged, but for IsRemovable which is always overwritten.
+//
+// Deprecated: unknown names are ignored, use MergeFields.
 func (l PropertiesList) Merge(some PropertiesList, what ...string) []*Properties {
-	for i, d := range l {
-		s := some.FindByPath(d.Path)
-		if s != nil {
-			for _, w := range what {
-				switch w {
-				case "Label":
-					if s.Label != "" {
-						d.Label = s.Label
-					}
-				case "Size":
-					if s.Size != "" {
-						d.Size = s.Size
-					}
-				case "SpaceLeft":
-					if s.SpaceLeft != "" {
-						d.SpaceLeft = s.SpaceLeft
-					}
-				case "MountPath":
-					if s.MountPath != "" {
-						d.MountPath = s.MountPath
-					}
-				case "Path":
-					if s.Path != "" {
-						d.Path = s.Path
-					}
-				case "IsRemovable":
-					d.IsRemovable = s.IsRemovable
-				}
-			}
-			l[i] = d
+	for _, w := range what {
+		f, err := ParseField(w)
+		if err != nil {
+			continue
 		}
+		policy := PreferNonEmpty
+		if f == FieldIsRemovable {
+			policy = LastWins
+		}
+		l, _ = l.MergeFields(some, NewFieldSet(f), policy)
 	}
 	return l
 }
 
 // Append some []*Properties into this list. what is a property name of Properties.
-// Partitions are matched by their Path value.
+// Partitions are matched by their MajorMinor value when both have one, by their Path value otherwise.
 func (l PropertiesList) Append(some PropertiesList) []*Properties {
+	index := l.Index()
 	for _, d := range some {
-		s := l.FindByPath(d.Path)
-		if s == nil {
-			l = append(l, d)
+		if index.find(d) == nil {
+			index.Add(d)
 		}
 	}
-	return l
+	return index.List()
 }
 
 // FindByPath search a partition by its path.
-// Partitions are matched by their Path value.
+// Partitions are matched by their Path value, or their Aliases.
 func (l PropertiesList) FindByPath(path string) *Properties {
 	for _, d := range l {
-		if d.Path == path {
+		if d.HasPath(path) {
+			return d
+		}
+	}
+	return nil
+}
+
+// FindByMountPath search a partition by its mount path.
+func (l PropertiesList) FindByMountPath(mountPath string) *Properties {
+	return l.Index().FindByMountPath(mountPath)
+}
+
+// FindContaining returns the mounted partition holding the file at filePath,
+// see PropertiesIndex.FindContaining.
+// Index the list to resolve many files.
+func (l PropertiesList) FindContaining(filePath string) *Properties {
+	return l.Index().FindContaining(filePath)
+}
+
+// FindByMajorMinor search a partition by its device number, such as 8:1.
+func (l PropertiesList) FindByMajorMinor(majorMinor string) *Properties {
+	for _, d := range l {
+		if d.MajorMinor == majorMinor {
 			return d
 		}
 	}
@@ -27,7 +27,7 @@ func (l *LineReader) ReadLine() (string, error) {
 	if isPrefix {
 		l.line += string(line)
 	} else if l.line != "" {
-		ret = l.line
+		ret = l.line + string(line)
 		l.line = ""
 	} else {
 		ret = string(line)
@@ -0,0 +1,59 @@
+package diskinfo
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DiskLinksReader reads a directory of symbolic links to block devices,
+// such as /dev/disk/by-label.
+type DiskLinksReader struct {
+	dir  string
+	path string
+}
+
+// NewDiskLinksReader reads the links of dir.
+// Relative link targets are resolved against path,
+// the location of dir on the live system, such as /dev/disk/by-label.
+func NewDiskLinksReader(dir, path string) *DiskLinksReader {
+	return &DiskLinksReader{dir: dir, path: path}
+}
+
+// Read returns a list of properties for each link found,
+// its Label is the decoded name of the link, its Path is the target of the link.
+// A missing directory is not an error, udev does not create empty directories.
+func (l *DiskLinksReader) Read() ([]*Properties, error) {
+	var ret []*Properties
+
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return ret, err
+	}
+
+	for _, e := range entries {
+		if e.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(l.dir, e.Name()))
+		if err != nil {
+			return ret, err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(l.path, target)
+		}
+		p := NewProperties()
+		p.Label = decodeUdevEscapes(e.Name())
+		p.Path = filepath.Clean(target)
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+// readDiskLinks reads the links of the /dev/disk directory path, under the roots.
+func readDiskLinks(roots Roots, path string) ([]*Properties, error) {
+	return NewDiskLinksReader(roots.Path(path), path).Read()
+}
@@ -1,55 +1,202 @@
 package diskinfo
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
-// LinuxLoader can load disk information for a linux system using df / fidsk.
+// LinuxLoader can load disk information for a linux system using /proc/self/mountinfo, statfs, /dev/disk and the udev database.
 type LinuxLoader struct {
+	// Runner runs the external commands, it defaults to an ExecRunner.
+	Runner CommandRunner
+	// Roots are the directories of the pseudo filesystems to read, they default to the live system.
+	Roots Roots
+	// SourceTimeout bounds the time spent on each source of information, zero means no limit.
+	SourceTimeout time.Duration
+	// MountTimeout bounds the time statfs is given for each mount point, it defaults to DefaultMountTimeout.
+	// A mount point that does not answer in time is reported as TimedOut, and is not probed again
+	// by the next loads until it answers.
+	MountTimeout time.Duration
+	// Concurrency is the number of sources loaded at the same time, it defaults to DefaultConcurrency.
+	// The results are merged in the same order whatever it is.
+	Concurrency int
+	// LvmCommands runs lvs, vgs and pvs to complete the LVM topology,
+	// such as the free space of the volume groups, they need root privileges.
+	LvmCommands bool
+	// ReadPartitionTables reads the partition tables of the disks to fill the partition types,
+	// it needs read access to the disk devices.
+	ReadPartitionTables bool
+	// ReadLuksHeaders reads the header of the LUKS devices to fill their cipher, and find the ones
+	// udev does not know, it needs read access to the devices. The udev database is used otherwise.
+	ReadLuksHeaders bool
+	// ProbeFilesystems reads the superblock of the devices to fill their filesystem type, label and UUID,
+	// when udev did not, it needs read access to the devices.
+	ProbeFilesystems bool
+
+	// statfs replaces the statfs of the live system, such as for the mount points of a fixture.
+	statfs statfsFunc
 }
 
 // Load returns the list of partition found and their properties.
 func (l *LinuxLoader) Load() ([]*Properties, error) {
-	//-
-	var ret PropertiesList
+	return l.LoadContext(context.Background())
+}
 
-	if temp, err := runDf(); err != nil {
-		return ret, err
-	} else {
-		ret = ret.Append(temp)
+// LoadContext is Load, it gives up when ctx is done.
+// Every source is run, when some of them fail the properties of the others
+// are returned with a *LoadError.
+func (l *LinuxLoader) LoadContext(ctx context.Context) ([]*Properties, error) {
+	return l.CompositeLoader().LoadContext(ctx)
+}
+
+// CompositeLoader returns a loader of the linux sources, register more sources
+// to complete the properties. Their names are mountinfo, partitions, by-label, disk-ids, udev, removable, mdraid and dmcrypt,
+// with priorities 0, 5, 10, 20, 30, 40, 50 and 60, partition-table at 25 with ReadPartitionTables,
+// and superblock at 35 with ProbeFilesystems.
+func (l *LinuxLoader) CompositeLoader() *CompositeLoader {
+	c := NewCompositeLoader()
+	c.SourceTimeout = l.SourceTimeout
+	c.Concurrency = l.Concurrency
+	c.begin = func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, loadNamesKey{}, &loadNames{roots: l.Roots})
 	}
-	//-
-	if temp, err := runLsLabel(); err != nil {
-		return ret, err
-	} else {
-		ret = ret.Append(temp)
+	c.Register(SourceInfo{Name: "mountinfo", Priority: 0, Adds: AllPartitions}, l.canonical(l.readMounts))
+	c.Register(SourceInfo{
+		Name:     "partitions",
+		Priority: 5,
+		// the size of the partition is only given to the unmounted ones,
+		// a mounted filesystem has the usage statfs reported.
+		Fields: NewFieldSet(FieldDisk, FieldPartitionNumber),
+		Adds:   AllPartitions,
+	}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return readPartitions(l.Roots)
+	}))
+	c.Register(SourceInfo{Name: "by-label", Priority: 10, Fields: NewFieldSet(FieldLabel), Adds: AllPartitions}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return readLabels(l.Roots)
+	}))
+	c.Register(SourceInfo{
+		Name:     "disk-ids",
+		Priority: 20,
+		Fields:   NewFieldSet(FieldUUID, FieldPartUUID, FieldPartLabel, FieldByID, FieldByPath),
+	}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return readDiskIDs(l.Roots)
+	}))
+	c.Register(SourceInfo{
+		Name:     "udev",
+		Priority: 30,
+		Fields:   NewFieldSet(FieldFSType, FieldLabel, FieldUUID, FieldPartUUID, FieldPartLabel),
+		Adds:     hasLabel,
+	}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return runUdevDB(l.Roots)
+	}))
+	c.Register(SourceInfo{
+		Name:     "removable",
+		Priority: 40,
+		Fields:   NewFieldSet(FieldIsRemovable, FieldTransport),
+	}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return readRemovable(l.Roots)
+	}))
+	c.Register(SourceInfo{
+		Name:     "mdraid",
+		Priority: 50,
+		Fields:   NewFieldSet(FieldRaid),
+		// the arrays are listed unmounted, or inactive, a failed array is the one to report.
+		Adds: AllPartitions,
+	}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return readRaidVolumes(l.Roots)
+	}))
+	c.Register(SourceInfo{
+		Name:     "dmcrypt",
+		Priority: 60,
+		Fields:   NewFieldSet(FieldEncryption),
+		Adds:     isLocked,
+	}, l.canonical(func(context.Context) ([]*Properties, error) {
+		return readEncryption(l.Roots, l.ReadLuksHeaders)
+	}))
+	if l.ReadPartitionTables {
+		c.Register(SourceInfo{
+			Name:     "partition-table",
+			Priority: 25,
+			Fields:   NewFieldSet(FieldDisk, FieldPartitionNumber, FieldPartitionType, FieldPartUUID, FieldPartLabel),
+		}, l.canonical(func(context.Context) ([]*Properties, error) {
+			return readPartitionTables(l.Roots)
+		}))
 	}
-	//-
-	if temp, err := runLsUsb(); err != nil {
-		return ret, err
-	} else {
-		ret = ret.Merge(temp, "IsRemovable")
+	if l.ProbeFilesystems {
+		c.Register(SourceInfo{
+			Name:     "superblock",
+			Priority: 35,
+			Fields:   NewFieldSet(FieldFSType, FieldLabel, FieldUUID),
+			Adds:     hasLabel,
+		}, l.canonical(func(context.Context) ([]*Properties, error) {
+			return readSuperblocks(l.Roots)
+		}))
 	}
-	//-
-	if temp, err := runMount(); err != nil {
+	return c
+}
+
+// canonical is the source load, the paths of its properties are canonicalized,
+// such as /dev/dm-0 to /dev/mapper/fedora-root, for them to be merged whatever the naming scheme.
+func (l *LinuxLoader) canonical(load func(ctx context.Context) ([]*Properties, error)) Source {
+	return SourceFunc(func(ctx context.Context) ([]*Properties, error) {
+		ret, err := load(ctx)
+		names, ok := ctx.Value(loadNamesKey{}).(*loadNames)
+		if !ok {
+			names = &loadNames{roots: l.Roots}
+		}
+		n := names.get()
+		for _, p := range ret {
+			n.canonicalize(p)
+		}
 		return ret, err
-	} else {
-		ret = ret.Merge(temp, "Label")
+	})
+}
+
+// readMounts lists the mount points, their usage is computed with statfs for the live system.
+func (l *LinuxLoader) readMounts(ctx context.Context) ([]*Properties, error) {
+	var prober *mountProber
+	if l.statfs != nil {
+		prober = newMountProber(l.statfs)
+	} else if l.Roots.isLiveProc() {
+		prober = liveMounts
 	}
-	//-
-	return ret, nil
+	timeout := l.MountTimeout
+	if timeout == 0 {
+		timeout = DefaultMountTimeout
+	}
+	return runStatfs(ctx, l.Roots, prober, timeout)
+}
+
+// LoadDisks returns the disks found in /sys/block, with their partitions,
+// the loaded properties are attached to them as volumes.
+func (l *LinuxLoader) LoadDisks() ([]*Disk, error) {
+	return l.LoadDisksContext(context.Background())
+}
+
+// LoadDisksContext is LoadDisks, it gives up when ctx is done.
+func (l *LinuxLoader) LoadDisksContext(ctx context.Context) ([]*Disk, error) {
+	props, loadErr := l.LoadContext(ctx)
+	if loadErr != nil && !IsPartial(loadErr) {
+		return nil, loadErr
+	}
+	disks, err := NewBlockDevicesReader(l.Roots.Path("/sys")).Read()
+	if err != nil {
+		return disks, err
+	}
+	attachVolumes(disks, props)
+	return disks, loadErr
 }
 
-func runLsLabel() ([]*Properties, error) {
+func readLabels(roots Roots) ([]*Properties, error) {
 	var ret []*Properties
 
-	path := "/dev/disk/by-label/"
-	disks, err := runLs(path)
+	path := "/dev/disk/by-label"
+	disks, err := readDiskLinks(roots, path)
 	if err != nil {
 		return disks, err
 	}
@@ -61,37 +208,68 @@ func runLsLabel() ([]*Properties, error) {
 	return ret, nil
 }
 
-func runLsUsb() ([]*Properties, error) {
-	var ret []*Properties
+// diskIDDirs are the /dev/disk directories holding stable identifiers of the partitions.
+var diskIDDirs = []string{"by-uuid", "by-partuuid", "by-partlabel", "by-path", "by-id"}
 
-	path := "/dev/disk/by-id/"
-	disks, err := runLs(path)
-	if err != nil {
-		return disks, err
-	}
+// readDiskIDs resolves the links of diskIDDirs, it returns a list of properties
+// with the identifiers of each partition found.
+func readDiskIDs(roots Roots) ([]*Properties, error) {
+	var ret PropertiesList
 
-	for _, disk := range disks {
-		if strings.HasPrefix(disk.Label, "usb-") {
-			disk.IsRemovable = true
-			ret = append(ret, disk)
+	for _, dir := range diskIDDirs {
+		links, err := readDiskLinks(roots, "/dev/disk/"+dir)
+		if err != nil {
+			return ret, err
+		}
+		for _, link := range links {
+			p := ret.FindByPath(link.Path)
+			if p == nil {
+				p = NewProperties()
+				p.Path = link.Path
+				ret = append(ret, p)
+			}
+			switch dir {
+			case "by-uuid":
+				p.UUID = link.Label
+			case "by-partuuid":
+				p.PartUUID = link.Label
+			case "by-partlabel":
+				p.PartLabel = link.Label
+			case "by-path":
+				p.ByPath = append(p.ByPath, link.Label)
+			case "by-id":
+				p.ByID = append(p.ByID, link.Label)
+			}
 		}
 	}
 
 	return ret, nil
 }
 
-// LsReader ...
+func hasLabel(p *Properties) bool {
+	return p.Label != ""
+}
+
+// isLocked selects the encrypted devices that are not opened.
+func isLocked(p *Properties) bool {
+	return p.Encryption != nil && p.Encryption.Locked
+}
+
+// LsReader reads a ls -l command output of a directory of links.
+//
+// Deprecated: ls output depends on the locale, use DiskLinksReader.
 type LsReader struct {
 	r    io.Reader
 	line string
 }
 
-// NewLsReader ...
+// NewLsReader parses a ls -l command output.
 func NewLsReader(r io.Reader) *LsReader {
 	return &LsReader{r: r}
 }
 
-// Read ...
+// Read parses a ls -l command output of the directory path,
+// it returns a list of properties for each link found.
 func (l *LsReader) Read(path string) ([]*Properties, error) {
 
 	/*
@@ -110,28 +288,16 @@ func (l *LsReader) Read(path string) ([]*Properties, error) {
 		err = err2
 
 		if i > 0 && line != "" {
-			props := strings.Split(line, " ")
-			s := []string{}
-			for _, p := range props {
-				p = strings.TrimSpace(p)
-				if p != "" {
-					s = append(s, p)
-				}
-			}
-			p := NewProperties()
-			name := s[8]
-			if strings.HasPrefix(name, "'") {
-				name = name[1:]
-			}
-			if strings.HasSuffix(name, "'") {
-				name = name[:len(name)-1]
+			name, target, ok := splitLsLine(line)
+			if !ok {
+				return ret, fmt.Errorf("ls: malformed line %q", line)
 			}
-			p.Label = strings.Replace(name, "\\x20", " ", -1)
-			abs, err2 := filepath.Abs(filepath.Join(path, s[10]))
-			if err2 != nil {
-				panic(err2)
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(path, target)
 			}
-			p.Path = abs
+			p := NewProperties()
+			p.Label = decodeUdevEscapes(name)
+			p.Path = filepath.Clean(target)
 			ret = append(ret, p)
 		}
 
@@ -148,72 +314,39 @@ func (l *LsReader) Read(path string) ([]*Properties, error) {
 	return ret, err
 }
 
-func runLs(path string) ([]*Properties, error) {
-	var ret []*Properties
-	_, err := os.Stat(path)
-	// when there is not any usb disk connected on the computer
-	// skip err and return to avoid errors about
-	// missing directory.
-	if os.IsNotExist(err) {
-		return ret, nil
-	}
-	cmd := exec.Command("ls", "-l", path)
-	cmd.Stderr = os.Stderr
-
-	sink, err := cmd.StdoutPipe()
-	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Start(); err2 != nil {
-		return ret, err2
-	}
-
-	ret, err = NewLsReader(sink).Read(path)
-	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Wait(); err2 != nil {
-		return ret, err2
-	}
-
-	return ret, err
-}
-
-func runDf() ([]*Properties, error) {
-	var ret []*Properties
-	cmd := exec.Command("df", "-h")
-	cmd.Stderr = os.Stderr
-
-	sink, err := cmd.StdoutPipe()
-	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Start(); err2 != nil {
-		return ret, err2
+// splitLsLine returns the name and the target of a link in a ls -l line,
+// the name is the text after the 8 columns of permissions, links, owner, group, size and date.
+func splitLsLine(line string) (string, string, bool) {
+	parts := strings.SplitN(line, " -> ", 2)
+	if len(parts) != 2 {
+		return "", "", false
 	}
-
-	ret, err = NewDfReader(sink).Read()
-	if err != nil {
-		return ret, err
+	left := parts[0]
+	for n := 0; n < 8; n++ {
+		left = strings.TrimLeft(left, " ")
+		i := strings.Index(left, " ")
+		if i < 0 {
+			return "", "", false
+		}
+		left = left[i:]
 	}
-
-	if err2 := cmd.Wait(); err2 != nil {
-		return ret, err2
+	name := strings.TrimSpace(left)
+	if len(name) > 1 && strings.HasPrefix(name, "'") && strings.HasSuffix(name, "'") {
+		name = name[1 : len(name)-1]
 	}
-
-	return ret, err
+	return name, strings.TrimSpace(parts[1]), name != ""
 }
 
-// DfReader ...
+// DfReader reads a df command output.
+//
+// Deprecated: df output depends on the locale, and df hangs on a network mount that does not answer,
+// LinuxLoader reads the filesystem usage with statfs.
 type DfReader struct {
 	r    io.Reader
 	line string
 }
 
-// NewDfReader ...
+// NewDfReader parses a df command output.
 func NewDfReader(r io.Reader) *DfReader {
 	return &DfReader{r: r}
 }
@@ -250,6 +383,10 @@ func (l *DfReader) Read() ([]*Properties, error) {
 			p.SpaceLeft = s[3]
 			p.Path = s[0]
 			p.MountPath = s[5]
+			p.Mounted = true
+			p.TotalBytes, _ = ParseByteSize(s[1])
+			p.UsedBytes, _ = ParseByteSize(s[2])
+			p.AvailableBytes, _ = ParseByteSize(s[3])
 			ret = append(ret, p)
 		}
 
@@ -265,39 +402,15 @@ func (l *DfReader) Read() ([]*Properties, error) {
 	return ret, err
 }
 
-func runMount() ([]*Properties, error) {
-	var ret []*Properties
-	cmd := exec.Command("mount", "-l")
-	cmd.Stderr = os.Stderr
-
-	sink, err := cmd.StdoutPipe()
-	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Start(); err2 != nil {
-		return ret, err2
-	}
-
-	ret, err = NewMountReader(sink).Read()
-	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Wait(); err2 != nil {
-		return ret, err2
-	}
-
-	return ret, err
-}
-
-// MountReader ...
+// MountReader reads a mount command output.
+//
+// Deprecated: mount output is ambiguous for the mount points with spaces, use MountinfoReader.
 type MountReader struct {
 	r    io.Reader
 	line string
 }
 
-// NewMountReader ...
+// NewMountReader parses a mount command output.
 func NewMountReader(r io.Reader) *MountReader {
 	return &MountReader{r: r}
 }
@@ -361,7 +474,11 @@ func (l *MountReader) Read() ([]*Properties, error) {
 				if s[0][:1] == "/" {
 					p := NewProperties()
 					p.MountPath = s[1]
+					p.Mounted = true
 					p.Path = s[0]
+					p.FSType = s[2]
+					p.MountOptions = ParseMountOptions(s[3])
+					p.ReadOnly = p.MountOptions.Has("ro")
 					if len(s) > 3 && len(s[4]) > 0 {
 						p.Label = strings.TrimSpace(s[4])
 						p.Label = p.Label[1 : len(p.Label)-1]
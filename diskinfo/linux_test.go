@@ -1,10 +1,18 @@
+//go:build linux
 // +build linux
 
 package diskinfo
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -229,3 +237,402 @@ gvfsd-fuse on /run/user/1001/gvfs type fuse.gvfsd-fuse (rw,nosuid,nodev,relatime
 		}
 	}
 }
+
+func TestStatfs(t *testing.T) {
+	u, err := statfs("/")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if u.Total == 0 {
+		t.Errorf("Expected the root filesystem to have a size")
+	}
+	if u.Available > u.Free || u.Free > u.Total {
+		t.Errorf("Inconsistent usage %#v", u)
+	}
+}
+
+func TestExecRunnerStderr(t *testing.T) {
+	_, err := ExecRunner{}.Run(context.Background(), "sh", "-c", "echo out; echo oops >&2; exit 3")
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("Expected a CommandError, got %#v", err)
+	}
+	if cmdErr.ExitStatus() != 3 || cmdErr.Stderr != "oops\n" {
+		t.Errorf("Unexpected error %#v", cmdErr)
+	}
+}
+
+// writeLinks creates the symbolic links of a fixture tree under root.
+func writeLinks(t testing.TB, root string, links map[string]string) {
+	for name, target := range links {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const sataDev = "sys/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda"
+
+// writeMachine writes the fixture of a machine with a sata disk of 3 partitions,
+// sda2 is mounted on /, sda1 has a label link, sda3 is only known by udev.
+func writeMachine(t testing.TB, root string) {
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": `23 28 0:22 / /proc rw,relatime shared:13 - proc proc rw
+28 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+`,
+		sataDev + "/dev":            "8:0\n",
+		sataDev + "/size":           "1953525168\n",
+		sataDev + "/removable":      "0\n",
+		sataDev + "/sda1/dev":       "8:1\n",
+		sataDev + "/sda1/partition": "1\n",
+		sataDev + "/sda1/start":     "2048\n",
+		sataDev + "/sda1/size":      "1048576\n",
+		sataDev + "/sda2/dev":       "8:2\n",
+		sataDev + "/sda2/partition": "2\n",
+		sataDev + "/sda2/start":     "1050624\n",
+		sataDev + "/sda2/size":      "67108864\n",
+		sataDev + "/sda3/dev":       "8:3\n",
+		sataDev + "/sda3/partition": "3\n",
+		sataDev + "/sda3/start":     "68159488\n",
+		sataDev + "/sda3/size":      "1885365680\n",
+		sataDev + "/trace/enable":   "0\n",
+		"run/udev/data/b8:3":        "S:disk/by-uuid/0b3c\nE:ID_FS_TYPE=ext4\nE:ID_FS_UUID=0b3c5d7e-aaaa-bbbb-cccc-1234567890ab\nE:ID_FS_LABEL=stockage\nE:ID_FS_LABEL_ENC=stockage\nE:ID_PART_ENTRY_UUID=9f1c2e3d-03\n",
+	})
+	writeLinks(t, root, map[string]string{
+		"sys/block/sda":                                                  "../devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda",
+		"sys/class/block/sda":                                            "../../devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda",
+		"sys/class/block/sda1":                                           "../../devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/sda1",
+		"dev/disk/by-label/Recovery":                                     "../../sda1",
+		"dev/disk/by-uuid/2C1E-7F0A":                                     "../../sda1",
+		"dev/disk/by-uuid/6a3c8f2e-1b44-4f8e-9d0c-7c1e2f3a4b5c":          "../../sda2",
+		"dev/disk/by-partuuid/9f1c2e3d-01":                               "../../sda1",
+		"dev/disk/by-partuuid/9f1c2e3d-02":                               "../../sda2",
+		`dev/disk/by-partlabel/EFI\x20System`:                            "../../sda1",
+		"dev/disk/by-path/pci-0000:00:1f.2-ata-1":                        "../../sda",
+		"dev/disk/by-path/pci-0000:00:1f.2-ata-1-part2":                  "../../sda2",
+		"dev/disk/by-id/ata-ST1000LM024_HN-M101MBB_S2ZUJ9CF201420-part2": "../../sda2",
+		"dev/disk/by-id/wwn-0x50004cf20f2a1b3c-part2":                    "../../sda2",
+	})
+}
+
+func TestLinuxLoaderFixture(t *testing.T) {
+	src := t.TempDir()
+	writeMachine(t, src)
+
+	var archive bytes.Buffer
+	if err := CaptureFixture(&archive, FixtureRoots(src)); err != nil {
+		t.Fatalf("Unexpected capture error %v", err)
+	}
+	dst := t.TempDir()
+	if err := ExtractFixture(&archive, dst); err != nil {
+		t.Fatalf("Unexpected extract error %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, sataDev, "trace")); !os.IsNotExist(err) {
+		t.Errorf("Expected the trace directory to be skipped")
+	}
+
+	loader := &LinuxLoader{Roots: FixtureRoots(dst)}
+	disks, err := loader.LoadDisks()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	if len(disks) != 1 || len(disks[0].Partitions) != 3 {
+		t.Fatalf("Unexpected disks %#v", disks)
+	}
+	parts := disks[0].Partitions
+	if v := parts[0].Volume; v == nil || v.Label != "Recovery" {
+		t.Errorf("Expected sda1 to be labelled Recovery, got %#v", v)
+	}
+	if v := parts[1].Volume; v == nil || v.MountPath != "/" || v.TotalBytes != 0 {
+		t.Errorf("Expected sda2 to be mounted on / without usage, got %#v", v)
+	}
+	if v := parts[2].Volume; v == nil || v.Label != "stockage" || v.MountPath != "" || v.FSType != "ext4" {
+		t.Errorf("Expected sda3 to be labelled stockage, got %#v", v)
+	}
+
+	if v := parts[0].Volume; v.UUID != "2C1E-7F0A" || v.PartUUID != "9f1c2e3d-01" || v.PartLabel != "EFI System" {
+		t.Errorf("Unexpected sda1 identifiers %#v", v)
+	}
+	v := parts[1].Volume
+	if v.UUID != "6a3c8f2e-1b44-4f8e-9d0c-7c1e2f3a4b5c" || v.PartUUID != "9f1c2e3d-02" {
+		t.Errorf("Unexpected sda2 identifiers %#v", v)
+	}
+	if !reflect.DeepEqual(v.ByID, []string{"ata-ST1000LM024_HN-M101MBB_S2ZUJ9CF201420-part2", "wwn-0x50004cf20f2a1b3c-part2"}) {
+		t.Errorf("Unexpected sda2 ids %#v", v.ByID)
+	}
+	if !reflect.DeepEqual(v.ByPath, []string{"pci-0000:00:1f.2-ata-1-part2"}) {
+		t.Errorf("Unexpected sda2 paths %#v", v.ByPath)
+	}
+	if v := parts[2].Volume; v.UUID != "0b3c5d7e-aaaa-bbbb-cccc-1234567890ab" || v.PartUUID != "9f1c2e3d-03" {
+		t.Errorf("Unexpected sda3 identifiers %#v", v)
+	}
+}
+
+func TestLinuxLoaderConcurrency(t *testing.T) {
+	root := t.TempDir()
+	writeMachine(t, root)
+
+	sequential, err := (&LinuxLoader{Roots: FixtureRoots(root), Concurrency: 1}).Load()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		parallel, err := (&LinuxLoader{Roots: FixtureRoots(root), Concurrency: 8}).Load()
+		if err != nil {
+			t.Fatalf("Unexpected load error %v", err)
+		}
+		if !reflect.DeepEqual(sequential, parallel) {
+			t.Fatalf("Expected the same properties in the same order\n%#v\n%#v", sequential, parallel)
+		}
+	}
+}
+
+func BenchmarkLinuxLoader(b *testing.B) {
+	root := b.TempDir()
+	writeMachine(b, root)
+
+	for _, bench := range []struct {
+		name        string
+		concurrency int
+	}{
+		{"sequential", 1},
+		{"parallel", DefaultConcurrency},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			loader := &LinuxLoader{Roots: FixtureRoots(root), Concurrency: bench.concurrency}
+			for i := 0; i < b.N; i++ {
+				if _, err := loader.Load(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLinuxLoaderDeviceMapper(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo":    "28 1 253:0 / / rw,relatime shared:1 - ext4 /dev/mapper/fedora-root rw\n",
+		"sys/block/dm-0/dev":     "253:0\n",
+		"sys/block/dm-0/size":    "67108864\n",
+		"sys/block/dm-0/dm/name": "fedora-root\n",
+	})
+	writeLinks(t, root, map[string]string{
+		"dev/disk/by-label/root":                                "../../dm-0",
+		"dev/disk/by-uuid/6a3c8f2e-1b44-4f8e-9d0c-7c1e2f3a4b5c": "../../dm-0",
+		"dev/disk/by-id/dm-name-fedora-root":                    "../../dm-0",
+	})
+
+	res, err := (&LinuxLoader{Roots: FixtureRoots(root)}).Load()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("Expected a single volume, got %#v", res)
+	}
+	p := res[0]
+	if p.Path != "/dev/mapper/fedora-root" || p.MountPath != "/" || p.Label != "root" || p.UUID != "6a3c8f2e-1b44-4f8e-9d0c-7c1e2f3a4b5c" {
+		t.Errorf("Unexpected properties %#v", p)
+	}
+	if !reflect.DeepEqual(p.Aliases, []string{"/dev/dm-0"}) || !reflect.DeepEqual(p.ByID, []string{"dm-name-fedora-root"}) {
+		t.Errorf("Unexpected aliases %#v", p)
+	}
+
+	disks, err := (&LinuxLoader{Roots: FixtureRoots(root)}).LoadDisks()
+	if err != nil || len(disks) != 1 || disks[0].Path != "/dev/mapper/fedora-root" || disks[0].Volume == nil {
+		t.Errorf("Unexpected disks %#v %v", disks, err)
+	}
+}
+
+func TestDiskLinksReader(t *testing.T) {
+	dev := t.TempDir()
+	writeLinks(t, dev, map[string]string{
+		"disk/by-label/Recovery":                             "../../sda1",
+		`disk/by-label/System\x20Reserved`:                   "../../sda2",
+		`disk/by-label/Backups\x2f2017\x20\xc3\xa9t\xc3\xa9`: "../../sdb1",
+		"disk/by-label/Absolute":                             "/dev/sdc1",
+	})
+	writeFiles(t, dev, map[string]string{
+		"disk/by-label/not-a-link": "",
+	})
+
+	res, err := NewDiskLinksReader(filepath.Join(dev, "disk/by-label"), "/dev/disk/by-label").Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expect := map[string]string{
+		"Recovery":         "/dev/sda1",
+		"System Reserved":  "/dev/sda2",
+		"Backups/2017 été": "/dev/sdb1",
+		"Absolute":         "/dev/sdc1",
+	}
+	if len(res) != len(expect) {
+		t.Errorf("Expected %v links, got %v", len(expect), len(res))
+	}
+	for _, p := range res {
+		if expect[p.Label] != p.Path {
+			t.Errorf("Unexpected link %q -> %q", p.Label, p.Path)
+		}
+	}
+
+	res, err = NewDiskLinksReader(filepath.Join(dev, "disk/by-partlabel"), "/dev/disk/by-partlabel").Read()
+	if err != nil || len(res) != 0 {
+		t.Errorf("Expected a missing directory to be empty, got %v %v", res, err)
+	}
+}
+
+func TestLsParserMalformed(t *testing.T) {
+	for _, in := range []string{
+		"total 0\nlrwxrwxrwx 1 root root 10 sda1\n",
+		"total 0\nlrwxrwxrwx 1 -> ../../sda1\n",
+	} {
+		_, err := NewLsReader(bytes.NewBufferString(in)).Read("/dev/disk/by-label/")
+		if err == nil {
+			t.Errorf("Expected an error for %q", in)
+		}
+	}
+	res, err := NewLsReader(bytes.NewBufferString("total 0\nlrwxrwxrwx 1 root root 10 27 févr. 11:04 My Disk -> ../../sda1\n")).Read("/dev/disk/by-label/")
+	if err != nil || len(res) != 1 || res[0].Label != "My Disk" || res[0].Path != "/dev/sda1" {
+		t.Errorf("Unexpected result %#v %v", res, err)
+	}
+}
+
+func TestBlockDevicesTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		device    string
+		attrs     map[string]string
+		transport string
+		removable bool
+		link      string // the device link of the disk
+	}{
+		{"sata", "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda",
+			map[string]string{"removable": "0\n"}, "", false, ""},
+		{"sata hot-plug", "devices/pci0000:00/0000:00:1f.2/ata2/host1/target1:0:0/1:0:0:0/block/sdb",
+			map[string]string{"removable": "0\n", "events_poll_msecs": "2000\n"}, "", true, ""},
+		{"optical", "devices/pci0000:00/0000:00:1f.2/ata3/host2/target2:0:0/2:0:0:0/block/sr0",
+			map[string]string{"removable": "1\n", "events_poll_msecs": "-1\n"}, "", true, ""},
+		{"usb stick", "devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdc",
+			map[string]string{"removable": "1\n"}, "usb", true, ""},
+		{"usb fixed disk", "devices/pci0000:00/0000:00:14.0/usb2/2-2/2-2:1.0/host7/target7:0:0/7:0:0:0/block/sdd",
+			map[string]string{"removable": "0\n"}, "usb", false, ""},
+		{"sd card", "devices/pci0000:00/0000:00:14.5/mmc_host/mmc0/mmc0:aaaa/block/mmcblk0",
+			map[string]string{"removable": "0\n", "../../type": "SD\n"}, "mmc", true, "../../../mmc0:aaaa"},
+		{"emmc", "devices/platform/fe330000.mmc/mmc_host/mmc1/mmc1:0001/block/mmcblk1",
+			map[string]string{"removable": "0\n", "../../type": "MMC\n"}, "mmc", false, "../../../mmc1:0001"},
+		{"firewire", "devices/pci0000:00/0000:00:1c.2/0000:03:00.0/fw1/fw1.0/host8/target8:0:0/8:0:0:0/block/sde",
+			map[string]string{"removable": "0\n"}, "ieee1394", true, ""},
+		{"thunderbolt nvme", "devices/pci0000:00/0000:00:07.0/0000:2c:00.0/0000:2d:01.0/nvme/nvme1/nvme1n1",
+			map[string]string{"removable": "0\n", "../../../removable": "removable\n"}, "thunderbolt", true, ""},
+		{"usb behind thunderbolt", "devices/pci0000:00/0000:00:07.0/0000:2c:00.0/usb3/3-1/3-1:1.0/host9/target9:0:0/9:0:0:0/block/sdf",
+			map[string]string{"removable": "0\n", "../../../../../../../removable": "removable\n"}, "usb", false, ""},
+	}
+	for _, test := range tests {
+		root := t.TempDir()
+		files := map[string]string{test.device + "/dev": "8:0\n", test.device + "/size": "1024\n"}
+		for name, content := range test.attrs {
+			files[filepath.Join(test.device, name)] = content
+		}
+		writeFiles(t, filepath.Join(root, "sys"), files)
+		name := filepath.Base(test.device)
+		links := map[string]string{"sys/block/" + name: "../" + test.device}
+		if test.link != "" {
+			links["sys/"+test.device+"/device"] = test.link
+		}
+		writeLinks(t, root, links)
+
+		// the transport is the same once the machine is captured.
+		var archive bytes.Buffer
+		if err := CaptureFixture(&archive, FixtureRoots(root)); err != nil {
+			t.Fatalf("%v: unexpected capture error %v", test.name, err)
+		}
+		fixture := t.TempDir()
+		if err := ExtractFixture(&archive, fixture); err != nil {
+			t.Fatalf("%v: unexpected extract error %v", test.name, err)
+		}
+
+		for _, dir := range []string{root, fixture} {
+			disks, err := NewBlockDevicesReader(filepath.Join(dir, "sys")).Read()
+			if err != nil || len(disks) != 1 {
+				t.Fatalf("%v: unexpected result %#v %v", test.name, disks, err)
+			}
+			if d := disks[0]; d.Transport != test.transport || d.IsRemovable != test.removable {
+				t.Errorf("%v: expected transport %q removable %v, got %q %v", test.name, test.transport, test.removable, d.Transport, d.IsRemovable)
+			}
+		}
+	}
+}
+
+func TestLinuxLoaderRemovable(t *testing.T) {
+	root := t.TempDir()
+	usb := "sys/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdb"
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo":   "28 1 8:17 / /run/media/whatever rw,relatime shared:1 - vfat /dev/sdb1 rw\n",
+		usb + "/dev":            "8:16\n",
+		usb + "/size":           "60437492\n",
+		usb + "/removable":      "1\n",
+		usb + "/sdb1/dev":       "8:17\n",
+		usb + "/sdb1/partition": "1\n",
+	})
+	writeLinks(t, root, map[string]string{
+		"sys/block/sdb": "../devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host6/target6:0:0/6:0:0:0/block/sdb",
+	})
+
+	res, err := (&LinuxLoader{Roots: FixtureRoots(root)}).Load()
+	if err != nil || len(res) != 1 {
+		t.Fatalf("Unexpected result %#v %v", res, err)
+	}
+	if p := res[0]; !p.IsRemovable || p.Transport != "usb" || p.SourceOf(FieldTransport) != "removable" {
+		t.Errorf("Expected sdb1 to be a removable usb partition, got %#v", p)
+	}
+}
+
+func TestExtractFixtureEscape(t *testing.T) {
+	type entry struct{ name, link, content string }
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"entry", []entry{{name: "../passwd", content: "x"}}},
+		// the absolute links lead to the temporary directory, for a failure not to write in /etc.
+		{"absolute link", []entry{{name: "a", link: "{parent}/a"}, {name: "a/passwd", content: "x"}}},
+		{"escaping link", []entry{{name: "dev/disk/a", link: "../../../etc"}}},
+		{"links chain", []entry{
+			{name: "a/b/up", link: "../.."},
+			{name: "x", link: "a/b/up/../.."},
+			{name: "x/passwd", content: "x"},
+		}},
+	}
+	for _, test := range tests {
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "a", "b", "fixture")
+
+		var archive bytes.Buffer
+		gz := gzip.NewWriter(&archive)
+		tw := tar.NewWriter(gz)
+		for _, e := range test.entries {
+			h := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+			if e.link != "" {
+				link := strings.Replace(e.link, "{parent}", parent, 1)
+				h = &tar.Header{Name: e.name, Linkname: link, Mode: 0777, Typeflag: tar.TypeSymlink}
+			}
+			if err := tw.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+			tw.Write([]byte(e.content))
+		}
+		tw.Close()
+		gz.Close()
+
+		if err := ExtractFixture(&archive, dir); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "a", "passwd")); err == nil {
+			t.Errorf("%v: a file was written outside of the fixture", test.name)
+		}
+	}
+}
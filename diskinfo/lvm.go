@@ -0,0 +1,349 @@
+package diskinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VolumeGroup is a LVM volume group, made of physical volumes, split in logical volumes.
+type VolumeGroup struct {
+	Name string
+	UUID string
+	// Size and Free are only known when the LVM commands are run, see LinuxLoader.LvmCommands.
+	Size            ByteSize `json:",omitempty"`
+	Free            ByteSize `json:",omitempty"`
+	LogicalVolumes  []*LogicalVolume
+	PhysicalVolumes []*PhysicalVolume
+}
+
+// LogicalVolume is a LVM logical volume, such as fedora/root.
+type LogicalVolume struct {
+	Name string
+	// Path is the device-mapper path of the volume, such as /dev/mapper/fedora-root.
+	Path       string
+	UUID       string
+	MajorMinor string `json:",omitempty"`
+	Size       ByteSize
+	Group      *VolumeGroup `json:"-"`
+	// Disk is the device-mapper block device of the volume, when it is active.
+	Disk *Disk `json:"-"`
+	// Volume is the filesystem of the logical volume, when it has one.
+	Volume *Volume `json:",omitempty"`
+}
+
+// PhysicalVolume is a disk or a partition of a LVM volume group.
+type PhysicalVolume struct {
+	Path       string
+	UUID       string       `json:",omitempty"`
+	MajorMinor string       `json:",omitempty"`
+	Size       ByteSize     `json:",omitempty"`
+	Free       ByteSize     `json:",omitempty"`
+	Group      *VolumeGroup `json:"-"`
+	// Disk is the disk of the physical volume, or the disk holding Partition.
+	Disk      *Disk      `json:"-"`
+	Partition *Partition `json:"-"`
+}
+
+// FindVolumeGroup search a volume group by its name.
+func FindVolumeGroup(groups []*VolumeGroup, name string) *VolumeGroup {
+	for _, g := range groups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// FindLogicalVolume search a logical volume of the group by its name.
+func (g *VolumeGroup) FindLogicalVolume(name string) *LogicalVolume {
+	for _, lv := range g.LogicalVolumes {
+		if lv.Name == name {
+			return lv
+		}
+	}
+	return nil
+}
+
+// FindPhysicalVolume search a physical volume of the group by its path.
+func (g *VolumeGroup) FindPhysicalVolume(path string) *PhysicalVolume {
+	for _, pv := range g.PhysicalVolumes {
+		if pv.Path == path {
+			return pv
+		}
+	}
+	return nil
+}
+
+// LoadVolumeGroups returns the LVM volume groups, their logical volumes are linked to
+// the disks and volumes LoadDisks returns, their physical volumes to the disks and partitions.
+func (l *LinuxLoader) LoadVolumeGroups() ([]*VolumeGroup, error) {
+	return l.LoadVolumeGroupsContext(context.Background())
+}
+
+// LoadVolumeGroupsContext is LoadVolumeGroups, it gives up when ctx is done.
+// The active logical volumes are found in sysfs, the LVM commands complete them when LvmCommands is set.
+func (l *LinuxLoader) LoadVolumeGroupsContext(ctx context.Context) ([]*VolumeGroup, error) {
+	disks, loadErr := l.LoadDisksContext(ctx)
+	if loadErr != nil && !IsPartial(loadErr) {
+		return nil, loadErr
+	}
+	groups := readVolumeGroups(l.Roots.Path("/sys"), disks)
+	if l.LvmCommands {
+		report, err := runLvmReports(ctx, runnerOrDefault(l.Runner))
+		if err != nil {
+			return groups, err
+		}
+		groups = report.completeGroups(groups, disks)
+	}
+	return groups, loadErr
+}
+
+// readVolumeGroups finds the active logical volumes among the disks,
+// they are device-mapper disks with a dm/uuid starting with LVM-,
+// their slaves are the physical volumes.
+func readVolumeGroups(sys string, disks []*Disk) []*VolumeGroup {
+	var ret []*VolumeGroup
+
+	for _, d := range disks {
+		dir := filepath.Join(sys, "block", d.Name)
+		vgUUID, lvUUID, ok := parseLvmDmUUID(readAttr(dir, "dm/uuid"))
+		if !ok {
+			continue
+		}
+		vgName, lvName := splitLvmDmName(readAttr(dir, "dm/name"))
+		g := FindVolumeGroup(ret, vgName)
+		if g == nil {
+			g = &VolumeGroup{Name: vgName, UUID: vgUUID}
+			ret = append(ret, g)
+		}
+		lv := &LogicalVolume{
+			Name:       lvName,
+			Path:       d.Path,
+			UUID:       lvUUID,
+			MajorMinor: d.MajorMinor,
+			Size:       d.Size,
+			Group:      g,
+			Disk:       d,
+			Volume:     d.Volume,
+		}
+		g.LogicalVolumes = append(g.LogicalVolumes, lv)
+
+		slaves, _ := ioutil.ReadDir(filepath.Join(dir, "slaves"))
+		for _, s := range slaves {
+			disk, part := findBlockDevice(disks, s.Name())
+			if disk == nil {
+				continue
+			}
+			pv := &PhysicalVolume{Path: disk.Path, MajorMinor: disk.MajorMinor, Size: disk.Size, Group: g, Disk: disk}
+			if part != nil {
+				pv.Path, pv.MajorMinor, pv.Size, pv.Partition = part.Path, part.MajorMinor, part.Size, part
+			}
+			// a logical volume stacked on another one, such as a thin volume, is not a physical volume.
+			if _, _, lvm := parseLvmDmUUID(readAttr(filepath.Join(sys, "block", s.Name()), "dm/uuid")); lvm {
+				continue
+			}
+			if g.FindPhysicalVolume(pv.Path) == nil {
+				g.PhysicalVolumes = append(g.PhysicalVolumes, pv)
+			}
+		}
+	}
+
+	return ret
+}
+
+// findBlockDevice search a disk, or a partition and its disk, by its sysfs name.
+func findBlockDevice(disks []*Disk, name string) (*Disk, *Partition) {
+	for _, d := range disks {
+		if d.Name == name {
+			return d, nil
+		}
+		for _, p := range d.Partitions {
+			if p.Name == name {
+				return d, p
+			}
+		}
+	}
+	return nil, nil
+}
+
+// parseLvmDmUUID parses the device-mapper uuid of a logical volume,
+// LVM-<32 chars volume group uuid><32 chars logical volume uuid>, with an optional -suffix for its sub volumes.
+func parseLvmDmUUID(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, "LVM-") || len(s) < 68 {
+		return "", "", false
+	}
+	return formatLvmUUID(s[4:36]), formatLvmUUID(s[36:68]), true
+}
+
+// formatLvmUUID formats a 32 chars LVM uuid as the LVM commands print it,
+// such as Xk1bCS-9Bs3-HoXb-l7kX-5S0k-JWmp-3vGYvE.
+func formatLvmUUID(s string) string {
+	if len(s) != 32 {
+		return s
+	}
+	var parts []string
+	for _, n := range []int{6, 4, 4, 4, 4, 4, 6} {
+		parts = append(parts, s[:n])
+		s = s[n:]
+	}
+	return strings.Join(parts, "-")
+}
+
+// splitLvmDmName splits a device-mapper name into its volume group and logical volume names,
+// the dashes of the names are doubled, as in my--vg-my--lv.
+func splitLvmDmName(s string) (string, string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '-' {
+			i++
+			continue
+		}
+		return strings.Replace(s[:i], "--", "-", -1), strings.Replace(s[i+1:], "--", "-", -1)
+	}
+	return strings.Replace(s, "--", "-", -1), ""
+}
+
+// LvmReport is the result of the lvs, vgs and pvs commands.
+type LvmReport struct {
+	LogicalVolumes  []map[string]string
+	VolumeGroups    []map[string]string
+	PhysicalVolumes []map[string]string
+}
+
+// LvmReportReader reads a lvs, vgs or pvs --reportformat json command output.
+type LvmReportReader struct {
+	r io.Reader
+}
+
+// NewLvmReportReader parses a lvs, vgs or pvs --reportformat json command output.
+func NewLvmReportReader(r io.Reader) *LvmReportReader {
+	return &LvmReportReader{r: r}
+}
+
+// Read parses a lvs, vgs or pvs --reportformat json command output,
+// it returns the rows of each of the lv, vg and pv tables found.
+func (l *LvmReportReader) Read() (*LvmReport, error) {
+
+	/*
+		{
+		    "report": [
+		        {
+		            "vg": [
+		                {"vg_name":"fedora", "vg_uuid":"Xk1bCS-9Bs3-HoXb-l7kX-5S0k-JWmp-3vGYvE", "vg_size":"1000203091968", "vg_free":"4194304"}
+		            ]
+		        }
+		    ]
+		}
+	*/
+
+	ret := &LvmReport{}
+	var doc struct {
+		Report []struct {
+			LV []map[string]string `json:"lv"`
+			VG []map[string]string `json:"vg"`
+			PV []map[string]string `json:"pv"`
+		} `json:"report"`
+	}
+	if err := json.NewDecoder(l.r).Decode(&doc); err != nil {
+		return ret, err
+	}
+	for _, r := range doc.Report {
+		ret.LogicalVolumes = append(ret.LogicalVolumes, r.LV...)
+		ret.VolumeGroups = append(ret.VolumeGroups, r.VG...)
+		ret.PhysicalVolumes = append(ret.PhysicalVolumes, r.PV...)
+	}
+	return ret, nil
+}
+
+// lvmCommands are the LVM commands run, and their columns.
+var lvmCommands = []struct {
+	name    string
+	columns string
+}{
+	{"vgs", "vg_name,vg_uuid,vg_size,vg_free"},
+	{"lvs", "vg_name,lv_name,lv_uuid,lv_size"},
+	{"pvs", "vg_name,pv_name,pv_uuid,pv_size,pv_free"},
+}
+
+func runLvmReports(ctx context.Context, r CommandRunner) (*LvmReport, error) {
+	ret := &LvmReport{}
+	for _, c := range lvmCommands {
+		out, err := r.Run(ctx, c.name, "--reportformat", "json", "--units", "b", "--nosuffix", "-o", c.columns)
+		if err != nil {
+			return ret, err
+		}
+		report, err := NewLvmReportReader(bytes.NewReader(out)).Read()
+		if err != nil {
+			return ret, err
+		}
+		ret.LogicalVolumes = append(ret.LogicalVolumes, report.LogicalVolumes...)
+		ret.VolumeGroups = append(ret.VolumeGroups, report.VolumeGroups...)
+		ret.PhysicalVolumes = append(ret.PhysicalVolumes, report.PhysicalVolumes...)
+	}
+	return ret, nil
+}
+
+// completeGroups adds the sizes of the report to the groups found in sysfs,
+// and the groups, the inactive logical volumes and the physical volumes sysfs does not show.
+func (r *LvmReport) completeGroups(groups []*VolumeGroup, disks []*Disk) []*VolumeGroup {
+	group := func(name string) *VolumeGroup {
+		g := FindVolumeGroup(groups, name)
+		if g == nil {
+			g = &VolumeGroup{Name: name}
+			groups = append(groups, g)
+		}
+		return g
+	}
+	for _, v := range r.VolumeGroups {
+		g := group(v["vg_name"])
+		g.UUID = v["vg_uuid"]
+		g.Size, _ = ParseByteSize(v["vg_size"])
+		g.Free, _ = ParseByteSize(v["vg_free"])
+	}
+	for _, v := range r.LogicalVolumes {
+		g := group(v["vg_name"])
+		lv := g.FindLogicalVolume(v["lv_name"])
+		if lv == nil {
+			lv = &LogicalVolume{Name: v["lv_name"], Group: g}
+			lv.Path = "/dev/mapper/" + strings.Replace(g.Name, "-", "--", -1) + "-" + strings.Replace(lv.Name, "-", "--", -1)
+			g.LogicalVolumes = append(g.LogicalVolumes, lv)
+		}
+		lv.UUID = v["lv_uuid"]
+		lv.Size, _ = ParseByteSize(v["lv_size"])
+	}
+	for _, v := range r.PhysicalVolumes {
+		if v["vg_name"] == "" {
+			// a physical volume of no group.
+			continue
+		}
+		g := group(v["vg_name"])
+		pv := g.FindPhysicalVolume(v["pv_name"])
+		if pv == nil {
+			pv = &PhysicalVolume{Path: v["pv_name"], Group: g}
+			for _, d := range disks {
+				if d.Path == pv.Path {
+					pv.Disk, pv.MajorMinor = d, d.MajorMinor
+				}
+				if p := d.FindPartition(pv.Path); p != nil {
+					pv.Disk, pv.Partition, pv.MajorMinor = d, p, p.MajorMinor
+				}
+			}
+			g.PhysicalVolumes = append(g.PhysicalVolumes, pv)
+		}
+		pv.UUID = v["pv_uuid"]
+		pv.Size, _ = ParseByteSize(v["pv_size"])
+		pv.Free, _ = ParseByteSize(v["pv_free"])
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
@@ -0,0 +1,112 @@
+package diskinfo
+
+import (
+	"testing"
+)
+
+func writeLvmMachine(t testing.TB, root string) {
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": `28 1 253:0 / / rw,relatime shared:1 - ext4 /dev/mapper/fedora-root rw
+40 28 253:1 / /home rw,relatime shared:20 - xfs /dev/mapper/fedora-home rw
+`,
+		"sys/block/sda/dev":            "8:0\n",
+		"sys/block/sda/size":           "1953525168\n",
+		"sys/block/sda/sda3/dev":       "8:3\n",
+		"sys/block/sda/sda3/partition": "3\n",
+		"sys/block/sda/sda3/size":      "1950351360\n",
+		"sys/block/sdb/dev":            "8:16\n",
+		"sys/block/sdb/size":           "976569616\n",
+		"sys/block/dm-0/dev":           "253:0\n",
+		"sys/block/dm-0/size":          "67108864\n",
+		"sys/block/dm-0/dm/name":       "fedora-root\n",
+		"sys/block/dm-0/dm/uuid":       "LVM-Xk1bCS9Bs3HoXbl7kX5S0kJWmp3vGYvE7WQnDsJ1gV3vLf1kPxMo8bRa2e5sWbQr\n",
+		"sys/block/dm-0/slaves/sda3":   "",
+		"sys/block/dm-1/dev":           "253:1\n",
+		"sys/block/dm-1/size":          "1844718720\n",
+		"sys/block/dm-1/dm/name":       "fedora-home\n",
+		"sys/block/dm-1/dm/uuid":       "LVM-Xk1bCS9Bs3HoXbl7kX5S0kJWmp3vGYvE2Qe9Rk8f1K0b7mZc1aYs6VTq4N9vDe2a\n",
+		"sys/block/dm-1/slaves/sda3":   "",
+	})
+}
+
+func TestLoadVolumeGroups(t *testing.T) {
+	root := t.TempDir()
+	writeLvmMachine(t, root)
+
+	groups, err := (&LinuxLoader{Roots: FixtureRoots(root)}).LoadVolumeGroups()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("Expected one volume group, got %v", len(groups))
+	}
+	g := groups[0]
+	if g.Name != "fedora" || g.UUID != "Xk1bCS-9Bs3-HoXb-l7kX-5S0k-JWmp-3vGYvE" || len(g.LogicalVolumes) != 2 {
+		t.Fatalf("Unexpected volume group %#v", g)
+	}
+	lv := g.FindLogicalVolume("root")
+	if lv == nil || lv.Path != "/dev/mapper/fedora-root" || lv.UUID != "7WQnDs-J1gV-3vLf-1kPx-Mo8b-Ra2e-5sWbQr" || lv.Size != 32*GiB || lv.Group != g {
+		t.Fatalf("Unexpected logical volume %#v", lv)
+	}
+	if lv.Volume == nil || lv.Volume.MountPath != "/" || lv.Disk == nil || lv.Disk.Name != "dm-0" {
+		t.Errorf("Expected the root volume to be mounted on /, got %#v", lv.Volume)
+	}
+	if len(g.PhysicalVolumes) != 1 {
+		t.Fatalf("Expected one physical volume, got %v", len(g.PhysicalVolumes))
+	}
+	pv := g.PhysicalVolumes[0]
+	if pv.Path != "/dev/sda3" || pv.MajorMinor != "8:3" || pv.Partition == nil || pv.Disk == nil || pv.Disk.Name != "sda" {
+		t.Errorf("Unexpected physical volume %#v", pv)
+	}
+}
+
+func TestLoadVolumeGroupsCommands(t *testing.T) {
+	root := t.TempDir()
+	writeLvmMachine(t, root)
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root), Runner: NewReplayRunner("testdata/replay-lvm"), LvmCommands: true}
+	groups, err := loader.LoadVolumeGroups()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(groups) != 2 || groups[0].Name != "backup-vg" || groups[1].Name != "fedora" {
+		t.Fatalf("Unexpected volume groups %#v", groups)
+	}
+
+	fedora := groups[1]
+	if fedora.Size != 998579896320 || fedora.Free != 4*MiB || len(fedora.LogicalVolumes) != 2 {
+		t.Errorf("Unexpected volume group %#v", fedora)
+	}
+	if pv := fedora.FindPhysicalVolume("/dev/sda3"); pv == nil || pv.UUID != "c2hX8m-5RyQ-1Lz0-Gk9w-Nb6T-eV4u-3JpFd7" || pv.Free != 4*MiB || pv.Partition == nil {
+		t.Errorf("Unexpected physical volume %#v", pv)
+	}
+
+	backup := groups[0]
+	if backup.Free != 100103643136 || len(backup.LogicalVolumes) != 1 || len(backup.PhysicalVolumes) != 1 {
+		t.Fatalf("Unexpected volume group %#v", backup)
+	}
+	if lv := backup.LogicalVolumes[0]; lv.Path != "/dev/mapper/backup--vg-daily--snap" || lv.Disk != nil || lv.Size != 400000000000 {
+		t.Errorf("Expected an inactive logical volume, got %#v", lv)
+	}
+	if pv := backup.PhysicalVolumes[0]; pv.Path != "/dev/sdb" || pv.Disk == nil || pv.Disk.Name != "sdb" || pv.Partition != nil {
+		t.Errorf("Unexpected physical volume %#v", pv)
+	}
+}
+
+func TestSplitLvmDmName(t *testing.T) {
+	tests := []struct {
+		name   string
+		vg, lv string
+	}{
+		{"fedora-root", "fedora", "root"},
+		{"my--vg-my--lv", "my-vg", "my-lv"},
+		{"vg-lv--", "vg", "lv-"},
+		{"vg", "vg", ""},
+	}
+	for _, test := range tests {
+		vg, lv := splitLvmDmName(test.name)
+		if vg != test.vg || lv != test.lv {
+			t.Errorf("splitLvmDmName(%q) Expected %q %q, got %q %q", test.name, test.vg, test.lv, vg, lv)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package diskinfo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MountinfoEntry is a mount point described by a line of /proc/self/mountinfo.
+type MountinfoEntry struct {
+	MountID        int
+	ParentID       int
+	Major          int
+	Minor          int
+	Root           string
+	MountPath      string
+	Options        string
+	OptionalFields []string
+	FSType         string
+	Source         string
+	SuperOptions   string
+}
+
+// MountinfoReader reads a /proc/[pid]/mountinfo file.
+type MountinfoReader struct {
+	r io.Reader
+}
+
+// NewMountinfoReader parses a /proc/[pid]/mountinfo file.
+func NewMountinfoReader(r io.Reader) *MountinfoReader {
+	return &MountinfoReader{r: r}
+}
+
+// ReadEntries parses the mountinfo content, it returns an entry for each mount point found.
+func (l *MountinfoReader) ReadEntries() ([]*MountinfoEntry, error) {
+
+	/*
+		36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		(1)(2)(3)   (4)   (5)      (6)      (7)   (8) (9)   (10)         (11)
+	*/
+
+	var ret []*MountinfoEntry
+
+	b := NewLineReader(l.r)
+	var err error
+	for {
+		line, err2 := b.ReadLine()
+		err = err2
+
+		if line != "" {
+			e, err3 := parseMountinfoLine(line)
+			if err3 != nil {
+				return ret, err3
+			}
+			ret = append(ret, e)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+	return ret, err
+}
+
+// Read parses the mountinfo content, it returns a list of properties
+// for each mounted block device found.
+func (l *MountinfoReader) Read() ([]*Properties, error) {
+	var ret []*Properties
+	entries, err := l.ReadEntries()
+	for _, e := range entries {
+		if strings.HasPrefix(e.Source, "/") {
+			p := NewProperties()
+			p.Path = e.Source
+			p.MountPath = e.MountPath
+			p.Mounted = true
+			p.MajorMinor = fmt.Sprintf("%d:%d", e.Major, e.Minor)
+			p.FSType = e.FSType
+			p.MountOptions, p.ReadOnly = mergeMountOptions(e.Options, e.SuperOptions)
+			ret = append(ret, p)
+		}
+	}
+	return ret, err
+}
+
+func parseMountinfoLine(line string) (*MountinfoEntry, error) {
+	fields := strings.Fields(line)
+	sep := -1
+	for i, f := range fields {
+		if f == "-" && i >= 6 {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return nil, fmt.Errorf("mountinfo: malformed line %q", line)
+	}
+
+	e := &MountinfoEntry{}
+	var err error
+	if e.MountID, err = strconv.Atoi(fields[0]); err != nil {
+		return nil, fmt.Errorf("mountinfo: invalid mount id in line %q", line)
+	}
+	if e.ParentID, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, fmt.Errorf("mountinfo: invalid parent id in line %q", line)
+	}
+	dev := strings.SplitN(fields[2], ":", 2)
+	if len(dev) != 2 {
+		return nil, fmt.Errorf("mountinfo: invalid major:minor in line %q", line)
+	}
+	if e.Major, err = strconv.Atoi(dev[0]); err != nil {
+		return nil, fmt.Errorf("mountinfo: invalid major in line %q", line)
+	}
+	if e.Minor, err = strconv.Atoi(dev[1]); err != nil {
+		return nil, fmt.Errorf("mountinfo: invalid minor in line %q", line)
+	}
+	e.Root = unescapeOctal(fields[3])
+	e.MountPath = unescapeOctal(fields[4])
+	e.Options = fields[5]
+	if sep > 6 {
+		e.OptionalFields = fields[6:sep]
+	}
+	e.FSType = unescapeOctal(fields[sep+1])
+	e.Source = unescapeOctal(fields[sep+2])
+	if len(fields) > sep+3 {
+		e.SuperOptions = fields[sep+3]
+	}
+	return e, nil
+}
+
+// unescapeOctal decodes the \ooo sequences the kernel uses
+// to escape spaces, tabs, new lines and backslashes in mount tables.
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+func readMountinfo(roots Roots) ([]*MountinfoEntry, error) {
+	f, err := os.Open(roots.Path("/proc/self/mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewMountinfoReader(f).ReadEntries()
+}
@@ -0,0 +1,133 @@
+package diskinfo
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMountinfoEntries(t *testing.T) {
+
+	in := `23 28 0:22 / /proc rw,relatime shared:13 - proc proc rw
+28 1 253:0 / / rw,relatime shared:1 - ext4 /dev/mapper/fedora-root rw,data=ordered
+61 28 8:5 / /home rw,relatime shared:30 master:2 - ext4 /dev/sda5 rw,data=ordered
+70 61 8:5 /mh-cbon/src /srv/src rw,relatime - ext4 /dev/sda5 rw,data=ordered
+95 28 8:17 / /run/media/mh-cbon/My\040Disk rw,nosuid,nodev,relatime shared:50 - fuseblk /dev/sdb1 rw,user_id=0,group_id=0,allow_other,blksize=4096
+`
+	expect := []*MountinfoEntry{
+		&MountinfoEntry{
+			MountID: 23, ParentID: 28, Major: 0, Minor: 22,
+			Root: "/", MountPath: "/proc", Options: "rw,relatime",
+			OptionalFields: []string{"shared:13"},
+			FSType:         "proc", Source: "proc", SuperOptions: "rw",
+		},
+		&MountinfoEntry{
+			MountID: 28, ParentID: 1, Major: 253, Minor: 0,
+			Root: "/", MountPath: "/", Options: "rw,relatime",
+			OptionalFields: []string{"shared:1"},
+			FSType:         "ext4", Source: "/dev/mapper/fedora-root", SuperOptions: "rw,data=ordered",
+		},
+		&MountinfoEntry{
+			MountID: 61, ParentID: 28, Major: 8, Minor: 5,
+			Root: "/", MountPath: "/home", Options: "rw,relatime",
+			OptionalFields: []string{"shared:30", "master:2"},
+			FSType:         "ext4", Source: "/dev/sda5", SuperOptions: "rw,data=ordered",
+		},
+		&MountinfoEntry{
+			MountID: 70, ParentID: 61, Major: 8, Minor: 5,
+			Root: "/mh-cbon/src", MountPath: "/srv/src", Options: "rw,relatime",
+			FSType: "ext4", Source: "/dev/sda5", SuperOptions: "rw,data=ordered",
+		},
+		&MountinfoEntry{
+			MountID: 95, ParentID: 28, Major: 8, Minor: 17,
+			Root: "/", MountPath: "/run/media/mh-cbon/My Disk", Options: "rw,nosuid,nodev,relatime",
+			OptionalFields: []string{"shared:50"},
+			FSType:         "fuseblk", Source: "/dev/sdb1", SuperOptions: "rw,user_id=0,group_id=0,allow_other,blksize=4096",
+		},
+	}
+
+	var b bytes.Buffer
+	r := NewMountinfoReader(bufio.NewReader(&b))
+	b.WriteString(in)
+
+	res, err := r.ReadEntries()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(res) != len(expect) {
+		t.Fatalf("Expected %v entries, got %v", len(expect), len(res))
+	}
+	for i, e := range expect {
+		if !reflect.DeepEqual(e, res[i]) {
+			t.Errorf("Entry(%v): expected\n%#v\ngot\n%#v", i, e, res[i])
+		}
+	}
+}
+
+func TestMountinfoParser(t *testing.T) {
+
+	overlay := "lowerdir=" + strings.Repeat("/var/lib/docker/overlay2/l/ABCDEFGHIJKLMNOPQRSTUVWXYZ:", 100)
+
+	testsTable := []parseTable{
+		parseTable{
+			in: `23 28 0:22 / /proc rw,relatime shared:13 - proc proc rw
+28 1 253:0 / / rw,relatime shared:1 - ext4 /dev/mapper/fedora-root rw,data=ordered
+30 28 0:40 / /var/lib/docker/overlay2/merged rw,relatime - overlay overlay rw,` + overlay + `
+61 28 8:5 / /home rw,relatime shared:30 - ext4 /dev/sda5 rw,data=ordered
+95 28 8:17 / /run/media/mh-cbon/My\040Disk rw,nosuid,nodev,relatime shared:50 - fuseblk /dev/sdb1 rw,allow_other
+`,
+			expectErr: nil,
+			expectOut: []*Properties{
+				&Properties{
+					MountPath: "/",
+					Path:      "/dev/mapper/fedora-root",
+				},
+				&Properties{
+					MountPath: "/home",
+					Path:      "/dev/sda5",
+				},
+				&Properties{
+					MountPath: "/run/media/mh-cbon/My Disk",
+					Path:      "/dev/sdb1",
+				},
+			},
+		},
+	}
+
+	for i, testTable := range testsTable {
+
+		var b bytes.Buffer
+		r := NewMountinfoReader(bufio.NewReader(&b))
+		b.WriteString(testTable.in)
+
+		res, err := r.Read()
+		if err != nil && testTable.expectErr != err {
+			t.Fatalf("Test(%v): Unexpected error %v", i, err)
+		}
+
+		for _, p := range res {
+			found := PropertiesList(testTable.expectOut).FindByPath(p.Path)
+			if found == nil {
+				t.Errorf("Test(%v): Unexpected property %q not found\n%#v\ntestTable.in=\n%v", i, p.Path, p, testTable.in)
+			} else if found.MountPath != p.MountPath {
+				t.Errorf("Test(%v): Property %q expected MountPath=%q, got %q", i, p.Path, found.MountPath, p.MountPath)
+			}
+		}
+
+		for _, p := range testTable.expectOut {
+			found := PropertiesList(res).FindByPath(p.Path)
+			if found == nil {
+				t.Errorf("Test(%v): Property %q not found\n%#v\ntestTable.in=\n%v", i, p.Path, p, testTable.in)
+			}
+		}
+	}
+}
+
+func TestMountinfoMalformed(t *testing.T) {
+	r := NewMountinfoReader(strings.NewReader("23 28 0:22 / /proc rw,relatime proc proc rw\n"))
+	if _, err := r.ReadEntries(); err == nil {
+		t.Errorf("Expected an error for a line without separator")
+	}
+}
@@ -0,0 +1,107 @@
+package diskinfo
+
+import (
+	"sort"
+	"strings"
+)
+
+// MountOptions are the options of a mount point.
+// Flags, such as ro or relatime, map to an empty value,
+// key=value options, such as uhelper=udisks2, map to their value.
+type MountOptions map[string]string
+
+// ParseMountOptions parses a comma separated list of mount options.
+func ParseMountOptions(s string) MountOptions {
+	ret := MountOptions{}
+	for _, o := range strings.Split(s, ",") {
+		if o == "" {
+			continue
+		}
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) == 2 {
+			ret[kv[0]] = kv[1]
+		} else {
+			ret[kv[0]] = ""
+		}
+	}
+	return ret
+}
+
+// Has tells if the option is set.
+func (o MountOptions) Has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
+
+// Get returns the value of a key=value option, or an empty string.
+func (o MountOptions) Get(name string) string {
+	return o[name]
+}
+
+// String returns the options as a sorted comma separated list.
+func (o MountOptions) String() string {
+	var ret []string
+	for k, v := range o {
+		if v != "" {
+			k += "=" + v
+		}
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ",")
+}
+
+// mergeMountOptions merges the per mount point options and the super block options.
+// A filesystem is read-only when any of them is ro, rw is then dropped.
+func mergeMountOptions(mount, super string) (MountOptions, bool) {
+	ret := ParseMountOptions(mount)
+	for k, v := range ParseMountOptions(super) {
+		if _, ok := ret[k]; !ok || v != "" {
+			ret[k] = v
+		}
+	}
+	readOnly := ret.Has("ro")
+	if readOnly {
+		delete(ret, "rw")
+	}
+	return ret, readOnly
+}
+
+// pseudoFSTypes are the filesystems without a backing storage.
+var pseudoFSTypes = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"efivarfs":    true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nfsd":        true,
+	"nsfs":        true,
+	"overlay":     true,
+	"proc":        true,
+	"pstore":      true,
+	"ramfs":       true,
+	"rpc_pipefs":  true,
+	"securityfs":  true,
+	"selinuxfs":   true,
+	"sysfs":       true,
+	"tmpfs":       true,
+	"tracefs":     true,
+}
+
+// IsPseudoFS tells if a filesystem type has no backing storage, such as proc, sysfs or tmpfs.
+func IsPseudoFS(fstype string) bool {
+	return pseudoFSTypes[fstype]
+}
+
+// IsPseudo tells if the partition is a pseudo filesystem, such as proc, sysfs or tmpfs.
+func (p *Properties) IsPseudo() bool {
+	return IsPseudoFS(p.FSType)
+}
@@ -0,0 +1,61 @@
+package diskinfo
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseMountOptions(t *testing.T) {
+	o := ParseMountOptions("rw,nosuid,nodev,relatime,user_id=0,group_id=0,default_permissions,allow_other,blksize=4096,uhelper=udisks2")
+	if !o.Has("nosuid") || o.Has("ro") || o.Get("uhelper") != "udisks2" || o.Get("blksize") != "4096" {
+		t.Errorf("Unexpected options %v", o)
+	}
+	expect := "allow_other,blksize=4096,default_permissions,group_id=0,nodev,nosuid,relatime,rw,uhelper=udisks2,user_id=0"
+	if o.String() != expect {
+		t.Errorf("Unexpected string %q", o.String())
+	}
+}
+
+func TestMergeMountOptions(t *testing.T) {
+	testsTable := []struct {
+		mount    string
+		super    string
+		expect   MountOptions
+		readOnly bool
+	}{
+		{"rw,relatime", "rw,data=ordered", MountOptions{"rw": "", "relatime": "", "data": "ordered"}, false},
+		{"ro,relatime", "rw,data=ordered", MountOptions{"ro": "", "relatime": "", "data": "ordered"}, true},
+		// ext4 remounted read-only after an I/O error, the mount point still says rw.
+		{"rw,relatime", "ro,errors=remount-ro", MountOptions{"ro": "", "relatime": "", "errors": "remount-ro"}, true},
+	}
+	for i, testTable := range testsTable {
+		o, ro := mergeMountOptions(testTable.mount, testTable.super)
+		if ro != testTable.readOnly || !reflect.DeepEqual(o, testTable.expect) {
+			t.Errorf("Test(%v): expected %v %v, got %v %v", i, testTable.expect, testTable.readOnly, o, ro)
+		}
+	}
+}
+
+func TestMountReaderOptions(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString(`tmpfs on /sys/fs/cgroup type tmpfs (ro,nosuid,nodev,noexec,mode=755)
+/dev/sda5 on /home type ext4 (ro,relatime,data=ordered)
+/dev/sdb1 on /run/media/mh-cbon/whatever type fuseblk (rw,nosuid,nodev,relatime,allow_other,blksize=4096,uhelper=udisks2) [whatever]
+`)
+	res, err := NewMountReader(bufio.NewReader(&b)).Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	l := PropertiesList(res)
+	if p := l.FindByPath("/dev/sda5"); p == nil || p.FSType != "ext4" || !p.ReadOnly || p.IsPseudo() {
+		t.Errorf("Unexpected properties %#v", p)
+	}
+	if p := l.FindByPath("/dev/sdb1"); p == nil || p.FSType != "fuseblk" || p.ReadOnly || p.MountOptions.Get("uhelper") != "udisks2" {
+		t.Errorf("Unexpected properties %#v", p)
+	}
+	if !IsPseudoFS("tmpfs") || !IsPseudoFS("proc") || IsPseudoFS("xfs") {
+		t.Errorf("Unexpected pseudo filesystems")
+	}
+}
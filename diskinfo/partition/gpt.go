@@ -0,0 +1,150 @@
+package partition
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+var gptSignature = []byte("EFI PART")
+
+// The bounds of the entries array, the specification uses 128 entries of 128 bytes.
+// A header may claim gigabytes, they are not allocated.
+const (
+	maxEntrySize   = 4096
+	maxEntriesSize = 1 << 20
+)
+
+type gptHeader struct {
+	backupLBA    uint64
+	firstUsable  uint64
+	lastUsable   uint64
+	diskGUID     string
+	entriesLBA   uint64
+	entriesCount uint32
+	entrySize    uint32
+	entriesCRC   uint32
+}
+
+// readGPT reads the primary GPT at LBA 1, and its backup at the last sector.
+func readGPT(r io.ReaderAt, size int64, sectorSize int) (*Table, error) {
+	primary, primaryEntries, primaryErr := readGPTAt(r, size, 1, sectorSize)
+	t := &Table{Scheme: GPT, SectorSize: sectorSize, PrimaryValid: primaryErr == nil}
+
+	backupLBA := uint64(size/int64(sectorSize)) - 1
+	if t.PrimaryValid {
+		backupLBA = primary.backupLBA
+	}
+	backup, backupEntries, backupErr := readGPTAt(r, size, backupLBA, sectorSize)
+	t.BackupValid = backupErr == nil
+
+	h, entries := primary, primaryEntries
+	switch {
+	case primaryErr == ErrNoTable && backupErr == ErrNoTable:
+		// not a GPT of this sector size.
+		return nil, ErrNoTable
+	case !t.PrimaryValid && !t.BackupValid:
+		return nil, ErrChecksum
+	case !t.PrimaryValid:
+		h, entries = backup, backupEntries
+	}
+	t.DiskID = h.diskGUID
+	t.FirstUsableLBA = h.firstUsable
+	t.LastUsableLBA = h.lastUsable
+	for i := 0; i < int(h.entriesCount); i++ {
+		e := entries[i*int(h.entrySize):]
+		if isZero(e[0:16]) {
+			continue
+		}
+		p := &Partition{
+			Number:     i + 1,
+			Type:       formatGUID(e[0:16]),
+			GUID:       formatGUID(e[16:32]),
+			FirstLBA:   binary.LittleEndian.Uint64(e[32:40]),
+			LastLBA:    binary.LittleEndian.Uint64(e[40:48]),
+			Attributes: binary.LittleEndian.Uint64(e[48:56]),
+			Name:       decodeUTF16(e[56:128]),
+		}
+		p.TypeName = TypeName(p.Type)
+		p.Offset = p.FirstLBA * uint64(sectorSize)
+		if p.LastLBA >= p.FirstLBA {
+			p.Size = (p.LastLBA - p.FirstLBA + 1) * uint64(sectorSize)
+		}
+		t.Partitions = append(t.Partitions, p)
+	}
+	return t, nil
+}
+
+// readGPTAt reads and verifies the GPT header at lba, and its entries,
+// which must fit in the size bytes of the device.
+func readGPTAt(r io.ReaderAt, size int64, lba uint64, sectorSize int) (*gptHeader, []byte, error) {
+	b := make([]byte, sectorSize)
+	if _, err := r.ReadAt(b, int64(lba)*int64(sectorSize)); err != nil {
+		return nil, nil, ErrNoTable
+	}
+	if !bytes.Equal(b[0:8], gptSignature) {
+		return nil, nil, ErrNoTable
+	}
+	hdrSize := binary.LittleEndian.Uint32(b[12:16])
+	if hdrSize < 92 || int(hdrSize) > sectorSize {
+		return nil, nil, fmt.Errorf("invalid GPT header size %v", hdrSize)
+	}
+	hdr := append([]byte(nil), b[:hdrSize]...)
+	want := binary.LittleEndian.Uint32(hdr[16:20])
+	copy(hdr[16:20], []byte{0, 0, 0, 0})
+	if crc32.ChecksumIEEE(hdr) != want {
+		return nil, nil, fmt.Errorf("invalid GPT header checksum at LBA %v", lba)
+	}
+	if current := binary.LittleEndian.Uint64(b[24:32]); current != lba {
+		return nil, nil, fmt.Errorf("GPT header at LBA %v claims to be at LBA %v", lba, current)
+	}
+	h := &gptHeader{
+		backupLBA:    binary.LittleEndian.Uint64(b[32:40]),
+		firstUsable:  binary.LittleEndian.Uint64(b[40:48]),
+		lastUsable:   binary.LittleEndian.Uint64(b[48:56]),
+		diskGUID:     formatGUID(b[56:72]),
+		entriesLBA:   binary.LittleEndian.Uint64(b[72:80]),
+		entriesCount: binary.LittleEndian.Uint32(b[80:84]),
+		entrySize:    binary.LittleEndian.Uint32(b[84:88]),
+		entriesCRC:   binary.LittleEndian.Uint32(b[88:92]),
+	}
+	total := int64(h.entriesCount) * int64(h.entrySize)
+	if h.entrySize < 128 || h.entrySize > maxEntrySize || h.entrySize%8 != 0 || total > maxEntriesSize ||
+		h.entriesLBA > uint64(size/int64(sectorSize)) || int64(h.entriesLBA)*int64(sectorSize)+total > size {
+		return nil, nil, fmt.Errorf("invalid GPT entries %v x %v bytes at LBA %v", h.entriesCount, h.entrySize, h.entriesLBA)
+	}
+	entries := make([]byte, total)
+	if _, err := r.ReadAt(entries, int64(h.entriesLBA)*int64(sectorSize)); err != nil {
+		return nil, nil, err
+	}
+	if crc32.ChecksumIEEE(entries) != h.entriesCRC {
+		return nil, nil, fmt.Errorf("invalid GPT entries checksum at LBA %v", h.entriesLBA)
+	}
+	return h, entries, nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeUTF16 decodes the NUL padded UTF-16LE name of a GPT entry.
+func decodeUTF16(b []byte) string {
+	var u []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return strings.TrimSpace(string(utf16.Decode(u)))
+}
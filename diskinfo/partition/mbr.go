@@ -0,0 +1,102 @@
+package partition
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const mbrSectorSize = 512
+
+// isExtended tells if a MBR type is an extended partition.
+func isExtended(t byte) bool {
+	return t == 0x05 || t == 0x0f || t == 0x85
+}
+
+type mbrEntry struct {
+	bootable bool
+	kind     byte
+	start    uint64
+	sectors  uint64
+}
+
+func parseMBREntries(b []byte) [4]mbrEntry {
+	var ret [4]mbrEntry
+	for i := range ret {
+		e := b[446+i*16:]
+		ret[i] = mbrEntry{
+			bootable: e[0] == 0x80,
+			kind:     e[4],
+			start:    uint64(binary.LittleEndian.Uint32(e[8:12])),
+			sectors:  uint64(binary.LittleEndian.Uint32(e[12:16])),
+		}
+	}
+	return ret
+}
+
+func (e mbrEntry) partition(number int, start uint64) *Partition {
+	p := &Partition{
+		Number:   number,
+		FirstLBA: start,
+		LastLBA:  start + e.sectors - 1,
+		Offset:   start * mbrSectorSize,
+		Size:     e.sectors * mbrSectorSize,
+		Type:     fmt.Sprintf("0x%02x", e.kind),
+		Bootable: e.bootable,
+	}
+	p.TypeName = TypeName(p.Type)
+	return p
+}
+
+// readMBR reads the primary partitions of the MBR, and the logical partitions of its extended partition.
+func readMBR(r io.ReaderAt, mbr []byte) (*Table, error) {
+	t := &Table{
+		Scheme:     MBR,
+		SectorSize: mbrSectorSize,
+		DiskID:     fmt.Sprintf("0x%08x", binary.LittleEndian.Uint32(mbr[440:444])),
+	}
+	for i, e := range parseMBREntries(mbr) {
+		if e.kind == 0 || e.sectors == 0 {
+			continue
+		}
+		t.Partitions = append(t.Partitions, e.partition(i+1, e.start))
+		if isExtended(e.kind) {
+			logical, err := readEBRs(r, e.start)
+			if err != nil {
+				return t, err
+			}
+			t.Partitions = append(t.Partitions, logical...)
+		}
+	}
+	return t, nil
+}
+
+// readEBRs follows the chain of extended boot records of the extended partition starting at sector ext.
+// The first entry of an EBR is a logical partition, relative to the EBR,
+// the second one links the next EBR, relative to the extended partition.
+func readEBRs(r io.ReaderAt, ext uint64) ([]*Partition, error) {
+	var ret []*Partition
+	ebr := make([]byte, mbrSectorSize)
+	seen := map[uint64]bool{}
+	for at := ext; !seen[at]; {
+		seen[at] = true
+		if _, err := r.ReadAt(ebr, int64(at*mbrSectorSize)); err != nil {
+			return ret, err
+		}
+		if binary.LittleEndian.Uint16(ebr[510:]) != 0xaa55 {
+			return ret, fmt.Errorf("invalid extended boot record at sector %v", at)
+		}
+		entries := parseMBREntries(ebr)
+		if e := entries[0]; e.kind != 0 && e.sectors != 0 {
+			p := e.partition(5+len(ret), at+e.start)
+			p.Logical = true
+			ret = append(ret, p)
+		}
+		next := entries[1]
+		if !isExtended(next.kind) || next.start == 0 {
+			break
+		}
+		at = ext + next.start
+	}
+	return ret, nil
+}
@@ -0,0 +1,131 @@
+// Package partition reads MBR and GPT partition tables of block devices and disk images.
+package partition
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Scheme is the kind of a partition table.
+type Scheme string
+
+// The partition table schemes.
+const (
+	MBR Scheme = "mbr"
+	GPT Scheme = "gpt"
+)
+
+var (
+	// ErrNoTable is returned when a device has no partition table.
+	ErrNoTable = errors.New("no partition table")
+	// ErrChecksum is returned when neither the primary nor the backup GPT are valid.
+	ErrChecksum = errors.New("invalid GPT checksums")
+)
+
+// Table is the partition table of a disk.
+type Table struct {
+	Scheme     Scheme
+	SectorSize int
+	// DiskID is the GUID of a GPT disk, or the 0xNNNNNNNN signature of a MBR disk.
+	DiskID string
+	// FirstUsableLBA and LastUsableLBA bound the sectors of the GPT partitions.
+	FirstUsableLBA uint64 `json:",omitempty"`
+	LastUsableLBA  uint64 `json:",omitempty"`
+	// PrimaryValid and BackupValid tell the GPT headers and entries passed their CRC32 checks,
+	// the partitions are read from the backup when the primary is invalid.
+	PrimaryValid bool `json:",omitempty"`
+	BackupValid  bool `json:",omitempty"`
+	Partitions   []*Partition
+}
+
+// Partition is an entry of a partition table.
+type Partition struct {
+	// Number is the number of the partition, as the kernel names it, sda1 is 1.
+	// The logical partitions of a MBR extended partition start at 5.
+	Number int
+	// FirstLBA and LastLBA are the first and the last sectors of the partition.
+	FirstLBA uint64
+	LastLBA  uint64
+	// Offset and Size are the bounds of the partition in bytes.
+	Offset uint64
+	Size   uint64
+	// Type is the GPT type GUID, or the 0xNN MBR type.
+	Type string
+	// TypeName describes a known Type, such as Linux filesystem.
+	TypeName string `json:",omitempty"`
+	// GUID is the unique GUID of a GPT partition.
+	GUID string `json:",omitempty"`
+	// Name is the name of a GPT partition.
+	Name string `json:",omitempty"`
+	// Attributes are the attribute flags of a GPT partition.
+	Attributes uint64 `json:",omitempty"`
+	// Bootable tells the MBR partition is active.
+	Bootable bool `json:",omitempty"`
+	// Logical tells the MBR partition is in an extended partition.
+	Logical bool `json:",omitempty"`
+}
+
+// Open reads the partition table of the block device or the disk image at path.
+func Open(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// the size of a block device is not reported by Stat.
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	return Read(f, size)
+}
+
+// Read reads the partition table of a device of size bytes.
+// A GPT is looked for with 512 and 4096 bytes sectors, a MBR otherwise.
+func Read(r io.ReaderAt, size int64) (*Table, error) {
+	mbr := make([]byte, 512)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		if err == io.EOF {
+			return nil, ErrNoTable
+		}
+		return nil, err
+	}
+	if binary.LittleEndian.Uint16(mbr[510:]) != 0xaa55 {
+		return nil, ErrNoTable
+	}
+	if isProtectiveMBR(mbr) {
+		var lastErr error = ErrNoTable
+		for _, sectorSize := range []int{512, 4096} {
+			t, err := readGPT(r, size, sectorSize)
+			if err == nil {
+				return t, nil
+			}
+			if err != ErrNoTable {
+				lastErr = err
+			}
+		}
+		return nil, lastErr
+	}
+	return readMBR(r, mbr)
+}
+
+func isProtectiveMBR(mbr []byte) bool {
+	for i := 0; i < 4; i++ {
+		if mbr[446+i*16+4] == 0xee {
+			return true
+		}
+	}
+	return false
+}
+
+// formatGUID formats the mixed-endian GUID of b, the first three fields are little-endian.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10], b[10:16])
+}
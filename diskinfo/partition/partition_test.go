@@ -0,0 +1,258 @@
+package partition
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+type testEntry struct {
+	kind, guid  string
+	first, last uint64
+	attributes  uint64
+	name        string
+}
+
+// putGUID writes the mixed-endian binary form of a GUID string.
+func putGUID(b []byte, s string) {
+	raw, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(raw) != 16 {
+		panic("invalid GUID " + s)
+	}
+	binary.LittleEndian.PutUint32(b[0:], binary.BigEndian.Uint32(raw[0:4]))
+	binary.LittleEndian.PutUint16(b[4:], binary.BigEndian.Uint16(raw[4:6]))
+	binary.LittleEndian.PutUint16(b[6:], binary.BigEndian.Uint16(raw[6:8]))
+	copy(b[8:], raw[8:])
+}
+
+// gptImage generates a disk image of the given sectors with a protective MBR,
+// a primary and a backup GPT of 128 entries.
+func gptImage(sectorSize int, sectors uint64, entries []testEntry) []byte {
+	img := make([]byte, uint64(sectorSize)*sectors)
+	mbr := img[446:]
+	mbr[4] = 0xee
+	binary.LittleEndian.PutUint32(mbr[8:], 1)
+	binary.LittleEndian.PutUint32(mbr[12:], uint32(sectors-1))
+	binary.LittleEndian.PutUint16(img[510:], 0xaa55)
+
+	table := make([]byte, 128*128)
+	for i, e := range entries {
+		if e.kind == "" {
+			continue
+		}
+		b := table[i*128:]
+		putGUID(b[0:], e.kind)
+		putGUID(b[16:], e.guid)
+		binary.LittleEndian.PutUint64(b[32:], e.first)
+		binary.LittleEndian.PutUint64(b[40:], e.last)
+		binary.LittleEndian.PutUint64(b[48:], e.attributes)
+		for j, c := range utf16.Encode([]rune(e.name)) {
+			binary.LittleEndian.PutUint16(b[56+j*2:], c)
+		}
+	}
+	tableSectors := uint64(len(table) / sectorSize)
+
+	header := func(current, backup, entriesLBA uint64) []byte {
+		h := make([]byte, 92)
+		copy(h, gptSignature)
+		binary.LittleEndian.PutUint32(h[8:], 0x00010000)
+		binary.LittleEndian.PutUint32(h[12:], 92)
+		binary.LittleEndian.PutUint64(h[24:], current)
+		binary.LittleEndian.PutUint64(h[32:], backup)
+		binary.LittleEndian.PutUint64(h[40:], 2+tableSectors)
+		binary.LittleEndian.PutUint64(h[48:], sectors-2-tableSectors)
+		putGUID(h[56:], "5f2c7a1e-9b3d-4e8f-a1c2-3d4e5f6a7b8c")
+		binary.LittleEndian.PutUint64(h[72:], entriesLBA)
+		binary.LittleEndian.PutUint32(h[80:], 128)
+		binary.LittleEndian.PutUint32(h[84:], 128)
+		binary.LittleEndian.PutUint32(h[88:], crc32.ChecksumIEEE(table))
+		binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+		return h
+	}
+	last := sectors - 1
+	copy(img[sectorSize:], header(1, last, 2))
+	copy(img[2*sectorSize:], table)
+	copy(img[last*uint64(sectorSize):], header(last, 1, last-tableSectors))
+	copy(img[(last-tableSectors)*uint64(sectorSize):], table)
+	return img
+}
+
+var testGPTEntries = []testEntry{
+	{"c12a7328-f81f-11d2-ba4b-00a0c93ec93b", "9f1c2e3d-0000-4a5b-8c7d-000000000001", 2048, 4095, 1, "EFI System Partition"},
+	{"0fc63daf-8483-4772-8e79-3d69d8477de4", "9f1c2e3d-0000-4a5b-8c7d-000000000002", 4096, 12287, 0, "données"},
+	{},
+	{"e6d6d379-f507-44c2-a23c-238f2a3df928", "9f1c2e3d-0000-4a5b-8c7d-000000000004", 12288, 16383, 1 << 60, ""},
+}
+
+func writeImage(t *testing.T, img []byte) string {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := ioutil.WriteFile(path, img, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGPT(t *testing.T) {
+	table, err := Open(writeImage(t, gptImage(512, 20480, testGPTEntries)))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if table.Scheme != GPT || table.SectorSize != 512 || table.DiskID != "5f2c7a1e-9b3d-4e8f-a1c2-3d4e5f6a7b8c" || !table.PrimaryValid || !table.BackupValid {
+		t.Errorf("Unexpected table %#v", table)
+	}
+	if table.FirstUsableLBA != 34 || table.LastUsableLBA != 20446 {
+		t.Errorf("Unexpected usable sectors %v %v", table.FirstUsableLBA, table.LastUsableLBA)
+	}
+	if len(table.Partitions) != 3 {
+		t.Fatalf("Expected 3 partitions, got %v", len(table.Partitions))
+	}
+	want := []Partition{
+		{Number: 1, FirstLBA: 2048, LastLBA: 4095, Offset: 1 << 20, Size: 1 << 20, Type: "c12a7328-f81f-11d2-ba4b-00a0c93ec93b", TypeName: "EFI System", GUID: "9f1c2e3d-0000-4a5b-8c7d-000000000001", Name: "EFI System Partition", Attributes: 1},
+		{Number: 2, FirstLBA: 4096, LastLBA: 12287, Offset: 2 << 20, Size: 4 << 20, Type: "0fc63daf-8483-4772-8e79-3d69d8477de4", TypeName: "Linux filesystem", GUID: "9f1c2e3d-0000-4a5b-8c7d-000000000002", Name: "données"},
+		{Number: 4, FirstLBA: 12288, LastLBA: 16383, Offset: 6 << 20, Size: 2 << 20, Type: "e6d6d379-f507-44c2-a23c-238f2a3df928", TypeName: "Linux LVM", GUID: "9f1c2e3d-0000-4a5b-8c7d-000000000004", Attributes: 1 << 60},
+	}
+	for i, p := range table.Partitions {
+		if *p != want[i] {
+			t.Errorf("Partition %v\nExpected %#v\ngot      %#v", i, want[i], *p)
+		}
+	}
+}
+
+func TestGPTBackup(t *testing.T) {
+	tests := []struct {
+		name          string
+		corrupt       []int64
+		primary       bool
+		backup        bool
+		err           error
+		partitionsLen int
+	}{
+		{"primary header", []int64{512 + 40}, false, true, nil, 3},
+		{"primary signature", []int64{512}, false, true, nil, 3},
+		{"primary entries", []int64{1024 + 200}, false, true, nil, 3},
+		{"backup header", []int64{20479*512 + 40}, true, false, nil, 3},
+		{"both headers", []int64{512 + 40, 20479*512 + 40}, false, false, ErrChecksum, 0},
+	}
+	for _, test := range tests {
+		img := gptImage(512, 20480, testGPTEntries)
+		for _, at := range test.corrupt {
+			img[at] ^= 0xff
+		}
+		table, err := Read(bytes.NewReader(img), int64(len(img)))
+		if err != test.err {
+			t.Errorf("%v: Expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if table.PrimaryValid != test.primary || table.BackupValid != test.backup || len(table.Partitions) != test.partitionsLen {
+			t.Errorf("%v: Unexpected table %#v", test.name, table)
+		}
+	}
+}
+
+// resizeEntries rewrites the entries count and size of the GPT header at off, with a valid checksum.
+func resizeEntries(img []byte, off int, count, size uint32) {
+	h := img[off : off+92]
+	binary.LittleEndian.PutUint32(h[80:], count)
+	binary.LittleEndian.PutUint32(h[84:], size)
+	binary.LittleEndian.PutUint32(h[16:], 0)
+	binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+}
+
+func TestGPTHugeEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		sectors     uint64
+		count, size uint32
+	}{
+		{"entry size", 20480, 1024, 16 << 20},
+		{"table size", 20480, 1 << 20, 128},
+		{"beyond the disk", 1024, 1024, 512},
+	}
+	for _, test := range tests {
+		img := gptImage(512, test.sectors, testGPTEntries)
+		resizeEntries(img, 512, test.count, test.size)
+		table, err := Read(bytes.NewReader(img), int64(len(img)))
+		if err != nil || table.PrimaryValid || !table.BackupValid || len(table.Partitions) != 3 {
+			t.Errorf("%v: Expected the backup to be used, got %#v %v", test.name, table, err)
+		}
+		resizeEntries(img, int(test.sectors-1)*512, test.count, test.size)
+		if _, err := Read(bytes.NewReader(img), int64(len(img))); err != ErrChecksum {
+			t.Errorf("%v: Expected ErrChecksum, got %v", test.name, err)
+		}
+	}
+}
+
+func TestGPT4K(t *testing.T) {
+	entries := []testEntry{
+		{"0fc63daf-8483-4772-8e79-3d69d8477de4", "9f1c2e3d-0000-4a5b-8c7d-000000000001", 256, 2047, 0, "data"},
+	}
+	img := gptImage(4096, 2560, entries)
+	table, err := Read(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if table.SectorSize != 4096 || len(table.Partitions) != 1 || table.Partitions[0].Offset != 1<<20 || table.Partitions[0].Size != 7<<20 {
+		t.Errorf("Unexpected table %#v", table)
+	}
+}
+
+func putMBREntry(b []byte, i int, bootable bool, kind byte, start, sectors uint32) {
+	e := b[446+i*16:]
+	if bootable {
+		e[0] = 0x80
+	}
+	e[4] = kind
+	binary.LittleEndian.PutUint32(e[8:], start)
+	binary.LittleEndian.PutUint32(e[12:], sectors)
+	binary.LittleEndian.PutUint16(b[510:], 0xaa55)
+}
+
+func TestMBR(t *testing.T) {
+	img := make([]byte, 512*40960)
+	binary.LittleEndian.PutUint32(img[440:], 0x1a2b3c4d)
+	putMBREntry(img, 0, true, 0x83, 2048, 8192)
+	putMBREntry(img, 1, false, 0x05, 10240, 20480)
+	// first EBR, a logical partition, and the link to the next EBR.
+	ebr := img[10240*512:]
+	putMBREntry(ebr, 0, false, 0x82, 2048, 4096)
+	putMBREntry(ebr, 1, false, 0x05, 8192, 12288)
+	ebr = img[(10240+8192)*512:]
+	putMBREntry(ebr, 0, false, 0x8e, 2048, 10240)
+
+	table, err := Read(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if table.Scheme != MBR || table.DiskID != "0x1a2b3c4d" || len(table.Partitions) != 4 {
+		t.Fatalf("Unexpected table %#v", table)
+	}
+	want := []Partition{
+		{Number: 1, FirstLBA: 2048, LastLBA: 10239, Offset: 2048 * 512, Size: 8192 * 512, Type: "0x83", TypeName: "Linux", Bootable: true},
+		{Number: 2, FirstLBA: 10240, LastLBA: 30719, Offset: 10240 * 512, Size: 20480 * 512, Type: "0x05", TypeName: "Extended"},
+		{Number: 5, FirstLBA: 12288, LastLBA: 16383, Offset: 12288 * 512, Size: 4096 * 512, Type: "0x82", TypeName: "Linux swap", Logical: true},
+		{Number: 6, FirstLBA: 20480, LastLBA: 30719, Offset: 20480 * 512, Size: 10240 * 512, Type: "0x8e", TypeName: "Linux LVM", Logical: true},
+	}
+	for i, p := range table.Partitions {
+		if *p != want[i] {
+			t.Errorf("Partition %v\nExpected %#v\ngot      %#v", i, want[i], *p)
+		}
+	}
+}
+
+func TestNoTable(t *testing.T) {
+	if _, err := Read(bytes.NewReader(make([]byte, 4096)), 4096); err != ErrNoTable {
+		t.Errorf("Expected ErrNoTable, got %v", err)
+	}
+	if _, err := Read(bytes.NewReader(nil), 0); err != ErrNoTable {
+		t.Errorf("Expected ErrNoTable, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package diskinfo
+
+import (
+	"github.com/mh-cbon/disksinfo/diskinfo/partition"
+)
+
+// readPartitionTables reads the partition tables of the disks,
+// it returns the partitions with their number, type, and GPT unique GUID and name.
+// The disks that can not be read, or have no partition table, are skipped.
+func readPartitionTables(roots Roots) ([]*Properties, error) {
+	var ret []*Properties
+
+	disks, err := NewBlockDevicesReader(roots.Path("/sys")).Read()
+	if err != nil {
+		return ret, err
+	}
+	for _, d := range disks {
+		if len(d.Partitions) == 0 {
+			continue
+		}
+		table, err := partition.Open(roots.Path(devPath(d.Name)))
+		if err != nil {
+			continue
+		}
+		for _, entry := range table.Partitions {
+			part := findPartitionNumber(d, entry.Number)
+			if part == nil {
+				continue
+			}
+			p := NewProperties()
+			p.Path = part.Path
+			p.MajorMinor = part.MajorMinor
+			p.Disk = d.Path
+			p.PartitionNumber = entry.Number
+			p.PartitionType = entry.Type
+			p.PartUUID = entry.GUID
+			p.PartLabel = entry.Name
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+func findPartitionNumber(d *Disk, number int) *Partition {
+	for _, p := range d.Partitions {
+		if p.Number == number {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package diskinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WindowsBackend is a source of disk information on windows.
+type WindowsBackend string
+
+// The windows backends.
+const (
+	// AutoBackend uses wmic, and falls back to PowerShell when wmic is not available.
+	AutoBackend WindowsBackend = ""
+	// WmicBackend uses wmic logicaldisk, it is deprecated since windows 10 21H1.
+	WmicBackend WindowsBackend = "wmic"
+	// PowerShellBackend uses the Get-Volume and Get-Partition cmdlets, since windows 8.
+	PowerShellBackend WindowsBackend = "powershell"
+)
+
+func runPowerShellCsv(ctx context.Context, r CommandRunner, cmdlet string) ([]byte, error) {
+	return r.Run(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", cmdlet+" | ConvertTo-Csv -NoTypeInformation")
+}
+
+func runPowerShell(ctx context.Context, r CommandRunner) ([]*Properties, error) {
+	var ret PropertiesList
+
+	out, err := runPowerShellCsv(ctx, r, "Get-Volume")
+	if err != nil {
+		return ret, err
+	}
+	volumes, err := NewVolumeCsvReader(bytes.NewReader(out)).Read()
+	if err != nil {
+		return ret, err
+	}
+	ret = ret.Append(volumes)
+
+	// AccessPaths is an array ConvertTo-Csv can not write, its paths are joined in VolumePaths.
+	out, err = runPowerShellCsv(ctx, r, "Get-Partition | Select-Object *,@{Name='VolumePaths';Expression={$_.AccessPaths -join '|'}}")
+	if err != nil {
+		return ret, err
+	}
+	partitions, err := NewPartitionCsvReader(bytes.NewReader(out)).Read()
+	if err != nil {
+		return ret, err
+	}
+	ret, _ = ret.MergeFields(partitions, NewFieldSet(FieldDisk, FieldPartitionNumber, FieldPartitionType, FieldAliases), PreferNonEmpty)
+	ret = ret.Append(partitions)
+
+	return ret, nil
+}
+
+// csvRecords reads a ConvertTo-Csv output, it returns a map of column name to value for each row.
+// The #TYPE line written without -NoTypeInformation is skipped.
+func csvRecords(r io.Reader) ([]map[string]string, error) {
+	var ret []map[string]string
+
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	c.Comment = '#'
+	c.LazyQuotes = true
+	var headers []string
+	for {
+		record, err := c.Read()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, err
+		}
+		if headers == nil {
+			headers = record
+			continue
+		}
+		values := map[string]string{}
+		for i, h := range headers {
+			if i < len(record) {
+				values[h] = strings.Trim(record[i], "\x00 ")
+			}
+		}
+		ret = append(ret, values)
+	}
+}
+
+// VolumeCsvReader reads a Get-Volume | ConvertTo-Csv command output.
+type VolumeCsvReader struct {
+	r io.Reader
+}
+
+// NewVolumeCsvReader parses a Get-Volume | ConvertTo-Csv command output.
+func NewVolumeCsvReader(r io.Reader) *VolumeCsvReader {
+	return &VolumeCsvReader{r: r}
+}
+
+// Read parses a Get-Volume | ConvertTo-Csv command output, it returns a list of properties for each volume found.
+// Volumes without drive letter are identified by their volume GUID path.
+func (l *VolumeCsvReader) Read() ([]*Properties, error) {
+
+	/*
+		"OperationalStatus","HealthStatus","DriveType","FileSystemType","DriveLetter","FileSystem","FileSystemLabel","Path","Size","SizeRemaining"
+		"OK","Healthy","Fixed","NTFS","C","NTFS","System","\\?\Volume{3b3c3e9a-0000-0000-0000-501f00000000}\","135996108800","16106127360"
+	*/
+
+	var ret []*Properties
+
+	records, err := csvRecords(l.r)
+	for _, v := range records {
+		p := NewProperties()
+		p.Path = v["Path"]
+		if v["DriveLetter"] != "" {
+			p.Path = v["DriveLetter"] + ":"
+			p.MountPath = p.Path
+			p.Mounted = true
+		}
+		p.Label = v["FileSystemLabel"]
+		p.FSType = v["FileSystem"]
+		p.Description = v["DriveType"]
+		p.IsRemovable = v["DriveType"] == "Removable"
+		p.Health = v["HealthStatus"]
+		if s, err := ParseByteSize(v["Size"]); err == nil {
+			p.TotalBytes = s
+			p.Size = s.String()
+		}
+		if s, err := ParseByteSize(v["SizeRemaining"]); err == nil {
+			p.FreeBytes = s
+			p.AvailableBytes = s
+			p.SpaceLeft = s.String()
+			if p.TotalBytes >= s {
+				p.UsedBytes = p.TotalBytes - s
+			}
+		}
+		ret = append(ret, p)
+	}
+
+	return ret, err
+}
+
+// volumeGUIDPath returns the \\?\Volume{GUID}\ path among the access paths of a partition, or an empty string.
+func volumeGUIDPath(accessPaths string) string {
+	for _, a := range strings.Split(accessPaths, "|") {
+		if strings.HasPrefix(a, `\\?\Volume{`) {
+			return a
+		}
+	}
+	return ""
+}
+
+// PartitionCsvReader reads a Get-Partition | ConvertTo-Csv command output,
+// with the AccessPaths of the partitions joined by | in a VolumePaths column.
+type PartitionCsvReader struct {
+	r io.Reader
+}
+
+// NewPartitionCsvReader parses a Get-Partition | ConvertTo-Csv command output.
+func NewPartitionCsvReader(r io.Reader) *PartitionCsvReader {
+	return &PartitionCsvReader{r: r}
+}
+
+// Read parses a Get-Partition | ConvertTo-Csv command output, it returns a list of properties for each partition found.
+// Partitions without drive letter are identified by the volume GUID path of their filesystem,
+// as Get-Volume does, or by their \\.\HarddiskNPartitionM device path, which is kept in the Aliases.
+func (l *PartitionCsvReader) Read() ([]*Properties, error) {
+
+	/*
+		"OperationalStatus","Type","DiskNumber","DriveLetter","GptType","MbrType","Offset","PartitionNumber","Size"
+		"Online","Basic","0","C","{ebd0a0a2-b9e5-4433-87c0-68b6b72699c7}","","122683392","3","135996108800"
+	*/
+
+	var ret []*Properties
+
+	records, err := csvRecords(l.r)
+	for _, v := range records {
+		p := NewProperties()
+		p.PartitionNumber, _ = strconv.Atoi(v["PartitionNumber"])
+		p.Disk = `\\.\PhysicalDrive` + v["DiskNumber"]
+		p.Path = fmt.Sprintf(`\\.\Harddisk%vPartition%v`, v["DiskNumber"], v["PartitionNumber"])
+		if v["DriveLetter"] != "" {
+			p.Path = v["DriveLetter"] + ":"
+			p.MountPath = p.Path
+			p.Mounted = true
+		} else if volume := volumeGUIDPath(v["VolumePaths"]); volume != "" {
+			p.addAlias(p.Path)
+			p.Path = volume
+		}
+		p.PartitionType = strings.Trim(v["GptType"], "{}")
+		if p.PartitionType == "" {
+			if t, err := strconv.Atoi(v["MbrType"]); err == nil {
+				p.PartitionType = fmt.Sprintf("0x%02x", t)
+			}
+		}
+		if s, err := ParseByteSize(v["Size"]); err == nil {
+			p.TotalBytes = s
+			p.Size = s.String()
+		}
+		ret = append(ret, p)
+	}
+
+	return ret, err
+}
@@ -0,0 +1,259 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func probeLUKS(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || !bytes.Equal(b[0:6], []byte("LUKS\xba\xbe")) {
+		return nil, err
+	}
+	res := &Result{
+		Type:    "crypto_LUKS",
+		UUID:    cString(b[168:208]),
+		Version: strconv.Itoa(int(binary.BigEndian.Uint16(b[6:8]))),
+	}
+	if res.Version == "2" {
+		res.Label = cString(b[24:72])
+	}
+	return res, nil
+}
+
+func probeXFS(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || !bytes.Equal(b[0:4], []byte("XFSB")) {
+		return nil, err
+	}
+	return &Result{Type: "xfs", UUID: formatUUID(b[32:48]), Label: cString(b[108:120])}, nil
+}
+
+// ext features telling ext4 and ext3 from ext2.
+const (
+	extCompatHasJournal     = 0x4
+	extIncompatJournalDev   = 0x8
+	extIncompatExtents      = 0x40
+	extIncompat64Bit        = 0x80
+	extIncompatFlexBG       = 0x200
+	extRoCompatHugeFile     = 0x8
+	extRoCompatGdtCsum      = 0x10
+	extRoCompatDirNlink     = 0x20
+	extRoCompatExtraIsize   = 0x40
+	extRoCompatMetadataCsum = 0x400
+)
+
+// probeExt reads the ext2/3/4 superblock at 1024.
+func probeExt(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 1024, 1024)
+	if b == nil || binary.LittleEndian.Uint16(b[0x38:]) != 0xef53 {
+		return nil, err
+	}
+	compat := binary.LittleEndian.Uint32(b[0x5c:])
+	incompat := binary.LittleEndian.Uint32(b[0x60:])
+	roCompat := binary.LittleEndian.Uint32(b[0x64:])
+	res := &Result{UUID: formatUUID(b[0x68:0x78]), Label: cString(b[0x78:0x88])}
+	switch {
+	case incompat&extIncompatJournalDev != 0:
+		res.Type = "jbd"
+	case incompat&(extIncompatExtents|extIncompat64Bit|extIncompatFlexBG) != 0,
+		roCompat&(extRoCompatHugeFile|extRoCompatGdtCsum|extRoCompatDirNlink|extRoCompatExtraIsize|extRoCompatMetadataCsum) != 0:
+		res.Type = "ext4"
+	case compat&extCompatHasJournal != 0:
+		res.Type = "ext3"
+	default:
+		res.Type = "ext2"
+	}
+	return res, nil
+}
+
+// probeBtrfs reads the btrfs superblock at 64KiB.
+func probeBtrfs(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0x10000, 0x300)
+	if b == nil || !bytes.Equal(b[0x40:0x48], []byte("_BHRfS_M")) {
+		return nil, err
+	}
+	return &Result{Type: "btrfs", UUID: formatUUID(b[0x20:0x30]), Label: cString(b[0x12b:0x22b])}, nil
+}
+
+// probeSwap looks for the signature at the end of the first page, for the usual page sizes.
+func probeSwap(r io.ReaderAt) (*Result, error) {
+	for _, page := range []int64{4096, 8192, 16384, 65536} {
+		sig, err := readAt(r, page-10, 10)
+		if sig == nil {
+			return nil, err
+		}
+		switch string(sig) {
+		case "SWAPSPACE2":
+			b, err := readAt(r, 1024, 44)
+			if b == nil {
+				return nil, err
+			}
+			// version, last_page, nr_badpages, uuid[16], volume_name[16].
+			return &Result{Type: "swap", Version: "1", UUID: formatUUID(b[12:28]), Label: cString(b[28:44])}, nil
+		case "SWAP-SPACE":
+			return &Result{Type: "swap", Version: "0"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// probeISO9660 reads the primary volume descriptor at 32KiB,
+// the UUID is the creation date, as blkid reports it.
+func probeISO9660(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0x8000, 2048)
+	if b == nil || b[0] != 1 || !bytes.Equal(b[1:6], []byte("CD001")) {
+		return nil, err
+	}
+	res := &Result{Type: "iso9660", Label: cString(b[40:72])}
+	if d := b[813:829]; isDate(d) {
+		res.UUID = fmt.Sprintf("%s-%s-%s-%s-%s-%s-%s", d[0:4], d[4:6], d[6:8], d[8:10], d[10:12], d[12:14], d[14:16])
+	}
+	return res, nil
+}
+
+// isDate tells the ISO9660 date d is set, its 16 bytes are digits, not all zeroes.
+func isDate(d []byte) bool {
+	set := false
+	for _, c := range d {
+		if c < '0' || c > '9' {
+			return false
+		}
+		set = set || c != '0'
+	}
+	return set
+}
+
+// probeExFAT reads the exFAT boot sector, and the volume label entry of the root directory.
+func probeExFAT(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || !bytes.Equal(b[3:11], []byte("EXFAT   ")) {
+		return nil, err
+	}
+	res := &Result{Type: "exfat", UUID: formatSerial(binary.LittleEndian.Uint32(b[100:]))}
+
+	heap := uint64(binary.LittleEndian.Uint32(b[88:]))
+	root := uint64(binary.LittleEndian.Uint32(b[96:]))
+	sectorShift, clusterShift := uint(b[108]), uint(b[109])
+	if root < 2 || sectorShift < 9 || sectorShift > 12 || clusterShift > 25-sectorShift {
+		return res, nil
+	}
+	clusterSize := 1 << (sectorShift + clusterShift)
+	dir, err := readAt(r, int64((heap+(root-2)<<clusterShift)<<sectorShift), clusterSize)
+	for i := 0; i+32 <= len(dir); i += 32 {
+		e := dir[i : i+32]
+		if e[0] == 0 {
+			break
+		}
+		if e[0] == 0x83 {
+			n := int(e[1])
+			if n > 11 {
+				n = 11
+			}
+			res.Label = utf16String(e[2 : 2+n*2])
+			break
+		}
+	}
+	return res, err
+}
+
+// probeNTFS reads the NTFS boot sector, and the volume name of the $Volume file,
+// the record 3 of the master file table.
+func probeNTFS(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || !bytes.Equal(b[3:11], []byte("NTFS    ")) {
+		return nil, err
+	}
+	res := &Result{Type: "ntfs", UUID: fmt.Sprintf("%016X", binary.LittleEndian.Uint64(b[0x48:]))}
+
+	sectorSize := int64(binary.LittleEndian.Uint16(b[0x0b:]))
+	clusterSize := sectorSize * int64(b[0x0d])
+	mft := int64(binary.LittleEndian.Uint64(b[0x30:])) * clusterSize
+	recordSize := int64(int8(b[0x40]))
+	if recordSize > 0 {
+		recordSize *= clusterSize
+	} else {
+		recordSize = 1 << uint(-recordSize)
+	}
+	if sectorSize < 256 || clusterSize == 0 || recordSize < sectorSize || recordSize > 65536 {
+		return res, nil
+	}
+	rec, err := readAt(r, mft+3*recordSize, int(recordSize))
+	if rec == nil || !bytes.Equal(rec[0:4], []byte("FILE")) || !applyFixups(rec) {
+		return res, err
+	}
+	for off := int(binary.LittleEndian.Uint16(rec[0x14:])); off+24 <= len(rec); {
+		kind := binary.LittleEndian.Uint32(rec[off:])
+		length := int(binary.LittleEndian.Uint32(rec[off+4:]))
+		if kind == 0xffffffff || length < 24 || off+length > len(rec) {
+			break
+		}
+		// a resident $VOLUME_NAME attribute.
+		if kind == 0x60 && rec[off+8] == 0 {
+			size := int(binary.LittleEndian.Uint32(rec[off+0x10:]))
+			start := off + int(binary.LittleEndian.Uint16(rec[off+0x14:]))
+			if start+size <= off+length {
+				res.Label = utf16String(rec[start : start+size])
+			}
+			break
+		}
+		off += length
+	}
+	return res, nil
+}
+
+// ntfsFixupStride is the size of the blocks of a NTFS record protected by the update sequence,
+// it is 512 bytes whatever the sector size of the disk.
+const ntfsFixupStride = 512
+
+// applyFixups restores the last two bytes of each 512 bytes block of a NTFS record,
+// they were replaced by the update sequence number to detect torn writes.
+func applyFixups(rec []byte) bool {
+	off := int(binary.LittleEndian.Uint16(rec[4:]))
+	count := int(binary.LittleEndian.Uint16(rec[6:]))
+	if count == 0 || off+count*2 > len(rec) || (count-1)*ntfsFixupStride > len(rec) {
+		return false
+	}
+	usn := rec[off : off+2]
+	for i := 1; i < count; i++ {
+		end := i*ntfsFixupStride - 2
+		if !bytes.Equal(rec[end:end+2], usn) {
+			return false
+		}
+		copy(rec[end:end+2], rec[off+i*2:off+i*2+2])
+	}
+	return true
+}
+
+// probeVFAT reads the FAT boot sector, FAT32 and FAT12/16 have their extended boot record at different offsets.
+func probeVFAT(r io.ReaderAt) (*Result, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || binary.LittleEndian.Uint16(b[510:]) != 0xaa55 {
+		return nil, err
+	}
+	bytesPerSector := binary.LittleEndian.Uint16(b[11:])
+	if b[13] == 0 || bytesPerSector < 512 || bytesPerSector&(bytesPerSector-1) != 0 || b[16] == 0 {
+		return nil, nil
+	}
+	var ebr []byte
+	switch {
+	case bytes.Equal(b[82:87], []byte("FAT32")):
+		ebr = b[64:]
+	case bytes.Equal(b[54:59], []byte("FAT12")), bytes.Equal(b[54:59], []byte("FAT16")):
+		ebr = b[36:]
+	default:
+		return nil, nil
+	}
+	res := &Result{Type: "vfat"}
+	// the extended boot signature, 0x29, tells the serial and the label are set.
+	if ebr[2] == 0x29 {
+		res.UUID = formatSerial(binary.LittleEndian.Uint32(ebr[3:]))
+		if label := cString(ebr[7:18]); label != "NO NAME" {
+			res.Label = label
+		}
+	}
+	return res, nil
+}
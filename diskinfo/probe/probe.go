@@ -0,0 +1,115 @@
+// Package probe identifies the filesystem of a block device or a disk image,
+// from its superblock, as blkid does.
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrUnknown is returned when no known filesystem is found.
+var ErrUnknown = errors.New("unknown filesystem")
+
+// Result is a filesystem found on a device.
+type Result struct {
+	// Type is the blkid name of the filesystem, such as ext4, vfat or crypto_LUKS.
+	Type  string
+	Label string `json:",omitempty"`
+	UUID  string `json:",omitempty"`
+	// Version is the version of the format, such as 2 for LUKS2, when it has one.
+	Version string `json:",omitempty"`
+}
+
+// prober recognizes a filesystem, it returns nil when r does not hold it.
+type prober func(r io.ReaderAt) (*Result, error)
+
+// probers are tried in order, the formats with a strong magic come first,
+// vfat, recognized by its boot sector, comes last.
+var probers = []prober{
+	probeLUKS,
+	probeXFS,
+	probeExt,
+	probeBtrfs,
+	probeSwap,
+	probeISO9660,
+	probeExFAT,
+	probeNTFS,
+	probeVFAT,
+}
+
+// Probe identifies the filesystem of r.
+// Most formats are found in the first MiB, btrfs at 64KiB, ISO9660 at 32KiB,
+// the NTFS label is read from the master file table.
+func Probe(r io.ReaderAt) (*Result, error) {
+	for _, p := range probers {
+		res, err := p(r)
+		if err != nil {
+			return nil, err
+		}
+		if res != nil {
+			return res, nil
+		}
+	}
+	return nil, ErrUnknown
+}
+
+// Open identifies the filesystem of the block device or the image at path.
+func Open(path string) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Probe(f)
+}
+
+// readAt reads n bytes at off, a short device reads as nil.
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// cString returns the NUL terminated string of b, without its trailing spaces.
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			b = b[:i]
+			break
+		}
+	}
+	return strings.TrimRight(string(b), " ")
+}
+
+// utf16String decodes the NUL terminated UTF-16LE string of b.
+func utf16String(b []byte) string {
+	var u []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), " ")
+}
+
+// formatUUID formats the 16 bytes of a big-endian UUID.
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// formatSerial formats a 32 bits volume serial number, as FAT and exFAT ones, such as 2C1E-7F0A.
+func formatSerial(v uint32) string {
+	return fmt.Sprintf("%04X-%04X", v>>16, v&0xffff)
+}
@@ -0,0 +1,250 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// putUUID writes the binary form of a UUID string.
+func putUUID(b []byte, s string) {
+	raw, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(raw) != 16 {
+		panic("invalid UUID " + s)
+	}
+	copy(b, raw)
+}
+
+func putUTF16(b []byte, s string) {
+	for i, c := range utf16.Encode([]rune(s)) {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+}
+
+func extImage(compat, incompat, roCompat uint32, uuid, label string) []byte {
+	img := make([]byte, 4096)
+	sb := img[1024:]
+	binary.LittleEndian.PutUint16(sb[0x38:], 0xef53)
+	binary.LittleEndian.PutUint32(sb[0x5c:], compat)
+	binary.LittleEndian.PutUint32(sb[0x60:], incompat)
+	binary.LittleEndian.PutUint32(sb[0x64:], roCompat)
+	putUUID(sb[0x68:], uuid)
+	copy(sb[0x78:], label)
+	return img
+}
+
+func xfsImage(uuid, label string) []byte {
+	img := make([]byte, 4096)
+	copy(img, "XFSB")
+	putUUID(img[32:], uuid)
+	copy(img[108:], label)
+	return img
+}
+
+func btrfsImage(uuid, label string) []byte {
+	img := make([]byte, 0x11000)
+	sb := img[0x10000:]
+	putUUID(sb[0x20:], uuid)
+	copy(sb[0x40:], "_BHRfS_M")
+	copy(sb[0x12b:], label)
+	return img
+}
+
+func swapImage(page int, uuid, label string) []byte {
+	img := make([]byte, page)
+	binary.LittleEndian.PutUint32(img[1024:], 1)
+	putUUID(img[1036:], uuid)
+	copy(img[1052:], label)
+	copy(img[page-10:], "SWAPSPACE2")
+	return img
+}
+
+func isoImage(label, created string) []byte {
+	img := make([]byte, 0x8000+2048)
+	pvd := img[0x8000:]
+	pvd[0] = 1
+	copy(pvd[1:], "CD001")
+	copy(pvd[40:], label+strings.Repeat(" ", 32-len(label)))
+	copy(pvd[813:], created)
+	return img
+}
+
+func luksImage(version uint16, uuid, label string) []byte {
+	img := make([]byte, 4096)
+	copy(img, "LUKS\xba\xbe")
+	binary.BigEndian.PutUint16(img[6:], version)
+	copy(img[24:], label)
+	copy(img[168:], uuid)
+	return img
+}
+
+// vfatImage generates a FAT boot sector, FAT32 or FAT16.
+func vfatImage(fat32 bool, serial uint32, label string) []byte {
+	img := make([]byte, 4096)
+	img[0], img[1], img[2] = 0xeb, 0x3c, 0x90
+	copy(img[3:], "mkfs.fat")
+	binary.LittleEndian.PutUint16(img[11:], 512)
+	img[13] = 4
+	img[16] = 2
+	ebr, kind := img[36:], "FAT16   "
+	if fat32 {
+		ebr, kind = img[64:], "FAT32   "
+	}
+	ebr[2] = 0x29
+	binary.LittleEndian.PutUint32(ebr[3:], serial)
+	copy(ebr[7:], label+strings.Repeat(" ", 11-len(label)))
+	copy(ebr[18:], kind)
+	binary.LittleEndian.PutUint16(img[510:], 0xaa55)
+	return img
+}
+
+// exfatImage generates an exFAT volume of 512 bytes sectors and clusters,
+// its root directory in the cluster 4 holds the volume label.
+func exfatImage(serial uint32, label string) []byte {
+	img := make([]byte, 64*512)
+	copy(img[3:], "EXFAT   ")
+	binary.LittleEndian.PutUint32(img[88:], 24)
+	binary.LittleEndian.PutUint32(img[96:], 4)
+	binary.LittleEndian.PutUint32(img[100:], serial)
+	img[108], img[109] = 9, 0
+	binary.LittleEndian.PutUint16(img[510:], 0xaa55)
+	root := img[(24+2)*512:]
+	// an allocation bitmap entry, then the label.
+	root[0] = 0x81
+	root[32] = 0x83
+	root[33] = byte(len([]rune(label)))
+	putUTF16(root[34:], label)
+	return img
+}
+
+// ntfsImage generates a NTFS volume of sectorSize bytes sectors and clusters, and 1024 bytes records,
+// or records of a sector when it is larger, its master file table at the cluster 8 holds the $Volume record and its name.
+func ntfsImage(sectorSize int, serial uint64, label string) []byte {
+	recordSize, recordShift := 1024, byte(10)
+	if sectorSize > recordSize {
+		recordSize, recordShift = 4096, 12
+	}
+	img := make([]byte, 8*sectorSize+4*recordSize)
+	copy(img[3:], "NTFS    ")
+	binary.LittleEndian.PutUint16(img[0x0b:], uint16(sectorSize))
+	img[0x0d] = 1
+	binary.LittleEndian.PutUint64(img[0x30:], 8)
+	img[0x40] = -recordShift // a negative shift, 1<<recordShift bytes records.
+	binary.LittleEndian.PutUint64(img[0x48:], serial)
+	binary.LittleEndian.PutUint16(img[510:], 0xaa55)
+
+	rec := img[8*sectorSize+3*recordSize:][:recordSize]
+	copy(rec, "FILE")
+	binary.LittleEndian.PutUint16(rec[4:], 0x30)
+	binary.LittleEndian.PutUint16(rec[6:], uint16(recordSize/512+1))
+	binary.LittleEndian.PutUint16(rec[0x14:], 0x48)
+	// a $STANDARD_INFORMATION attribute, then the $VOLUME_NAME one.
+	attr := rec[0x48:]
+	binary.LittleEndian.PutUint32(attr[0:], 0x10)
+	binary.LittleEndian.PutUint32(attr[4:], 0x60)
+	attr = attr[0x60:]
+	name := make([]byte, len(label)*2)
+	putUTF16(name, label)
+	binary.LittleEndian.PutUint32(attr[0:], 0x60)
+	binary.LittleEndian.PutUint32(attr[4:], uint32(0x18+len(name)+7)&^7)
+	binary.LittleEndian.PutUint32(attr[0x10:], uint32(len(name)))
+	binary.LittleEndian.PutUint16(attr[0x14:], 0x18)
+	copy(attr[0x18:], name)
+	end := 0x48 + 0x60 + int(binary.LittleEndian.Uint32(attr[4:]))
+	binary.LittleEndian.PutUint32(rec[end:], 0xffffffff)
+
+	// the update sequence replaces the last two bytes of each 512 bytes, whatever the sector size.
+	copy(rec[0x30:], []byte{0x01, 0x00})
+	for i := 1; i <= recordSize/512; i++ {
+		copy(rec[0x30+i*2:], rec[i*512-2:i*512])
+		copy(rec[i*512-2:], []byte{0x01, 0x00})
+	}
+	return img
+}
+
+func TestProbe(t *testing.T) {
+	const uuid = "2f3c5a1e-9b7d-4e61-8a0f-3d2c1b4a5e6f"
+	tests := []struct {
+		name string
+		img  []byte
+		want Result
+	}{
+		{"ext2", extImage(0, 0x2, 0x1, uuid, "boot"), Result{Type: "ext2", Label: "boot", UUID: uuid}},
+		{"ext3", extImage(0x4, 0x2, 0x1, uuid, "data"), Result{Type: "ext3", Label: "data", UUID: uuid}},
+		{"ext4", extImage(0x4, 0x2c2, 0x46b, uuid, "root"), Result{Type: "ext4", Label: "root", UUID: uuid}},
+		{"ext4 no label", extImage(0x4, 0x40, 0, uuid, ""), Result{Type: "ext4", UUID: uuid}},
+		{"xfs", xfsImage(uuid, "home"), Result{Type: "xfs", Label: "home", UUID: uuid}},
+		{"btrfs", btrfsImage(uuid, "pool"), Result{Type: "btrfs", Label: "pool", UUID: uuid}},
+		{"swap", swapImage(4096, uuid, "swap0"), Result{Type: "swap", Label: "swap0", UUID: uuid, Version: "1"}},
+		{"swap 64k pages", swapImage(65536, uuid, ""), Result{Type: "swap", UUID: uuid, Version: "1"}},
+		{"iso9660", isoImage("Fedora-WS-Live-40", "2024041618063300"), Result{Type: "iso9660", Label: "Fedora-WS-Live-40", UUID: "2024-04-16-18-06-33-00"}},
+		{"iso9660 no date", isoImage("CDROM", ""), Result{Type: "iso9660", Label: "CDROM"}},
+		{"iso9660 zero date", isoImage("CDROM", "0000000000000000"), Result{Type: "iso9660", Label: "CDROM"}},
+		{"luks1", luksImage(1, uuid, ""), Result{Type: "crypto_LUKS", UUID: uuid, Version: "1"}},
+		{"luks2", luksImage(2, uuid, "vault"), Result{Type: "crypto_LUKS", Label: "vault", UUID: uuid, Version: "2"}},
+		{"fat32", vfatImage(true, 0x2c1e7f0a, "EFI"), Result{Type: "vfat", Label: "EFI", UUID: "2C1E-7F0A"}},
+		{"fat16", vfatImage(false, 0x0000abcd, "NO NAME"), Result{Type: "vfat", UUID: "0000-ABCD"}},
+		{"exfat", exfatImage(0x1a2b3c4d, "Caméra"), Result{Type: "exfat", Label: "Caméra", UUID: "1A2B-3C4D"}},
+		{"ntfs", ntfsImage(512, 0x01d9c8b7a6f54e3d, "Windows"), Result{Type: "ntfs", Label: "Windows", UUID: "01D9C8B7A6F54E3D"}},
+		{"ntfs 4k sectors", ntfsImage(4096, 0x01d9c8b7a6f54e3d, "Windows"), Result{Type: "ntfs", Label: "Windows", UUID: "01D9C8B7A6F54E3D"}},
+	}
+	for _, test := range tests {
+		res, err := Probe(bytes.NewReader(test.img))
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err)
+			continue
+		}
+		if *res != test.want {
+			t.Errorf("%v: expected %#v, got %#v", test.name, test.want, *res)
+		}
+	}
+}
+
+func TestProbeUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		img  []byte
+	}{
+		{"empty", nil},
+		{"zeroes", make([]byte, 1<<20)},
+		{"mbr", func() []byte {
+			img := make([]byte, 4096)
+			binary.LittleEndian.PutUint16(img[510:], 0xaa55)
+			return img
+		}()},
+	}
+	for _, test := range tests {
+		if _, err := Probe(bytes.NewReader(test.img)); err != ErrUnknown {
+			t.Errorf("%v: expected ErrUnknown, got %v", test.name, err)
+		}
+	}
+}
+
+func TestProbeNTFSTornRecord(t *testing.T) {
+	img := ntfsImage(512, 1, "Windows")
+	// a torn write leaves a sector with a stale sequence number, the label is not trusted.
+	img[8*512+3*1024+510] = 0x02
+	res, err := Probe(bytes.NewReader(img))
+	if err != nil || res.Type != "ntfs" || res.Label != "" {
+		t.Errorf("Unexpected result %#v %v", res, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := ioutil.WriteFile(path, xfsImage("2f3c5a1e-9b7d-4e61-8a0f-3d2c1b4a5e6f", "srv"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Open(path)
+	if err != nil || res.Type != "xfs" || res.Label != "srv" {
+		t.Errorf("Unexpected result %#v %v", res, err)
+	}
+	if _, err := Open(path + ".missing"); err == nil {
+		t.Errorf("Expected an error for a missing image")
+	}
+}
@@ -0,0 +1,318 @@
+package diskinfo
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RaidArray is a linux software RAID array, such as /dev/md0.
+type RaidArray struct {
+	Name string
+	Path string
+	// Level is the RAID level, such as raid1.
+	Level string `json:",omitempty"`
+	// State is the state of the array, such as active, clean or inactive.
+	State string
+	// Devices is the number of devices of the array, Active the number of those in sync.
+	Devices int
+	Active  int
+	// Degraded tells the array lacks some devices.
+	Degraded bool
+	Members  []*RaidMember
+	// SyncAction is the running resync, recovery, reshape or check of the array, or an empty string.
+	SyncAction string `json:",omitempty"`
+	// SyncProgress is the percentage of SyncAction done.
+	SyncProgress float64 `json:",omitempty"`
+	// SyncRemaining is the time SyncAction is expected to take to finish.
+	SyncRemaining time.Duration `json:",omitempty"`
+	// SyncSpeed is the number of bytes SyncAction processes per second.
+	SyncSpeed ByteSize `json:",omitempty"`
+}
+
+// RaidMember is a device of a RaidArray.
+type RaidMember struct {
+	Name string
+	Path string
+	// Slot is the role of the device in the array, -1 for a spare.
+	// In mdstat, a device being recovered is numbered after the roles.
+	Slot int
+	// States are the states of the device, such as in_sync, faulty, spare or write_mostly.
+	States []string
+}
+
+// IsFaulty tells if the device failed.
+func (m *RaidMember) IsFaulty() bool {
+	return m.hasState("faulty")
+}
+
+// IsSpare tells if the device is a spare.
+func (m *RaidMember) IsSpare() bool {
+	return m.hasState("spare")
+}
+
+func (m *RaidMember) hasState(s string) bool {
+	for _, v := range m.States {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MdstatReader reads a /proc/mdstat file.
+type MdstatReader struct {
+	r io.Reader
+}
+
+// NewMdstatReader parses a /proc/mdstat file.
+func NewMdstatReader(r io.Reader) *MdstatReader {
+	return &MdstatReader{r: r}
+}
+
+var (
+	mdMemberR   = regexp.MustCompile(`^([^\[\s]+)\[(\d+)\]((?:\([A-Z]\))*)$`)
+	mdStatusR   = regexp.MustCompile(`\[(\d+)/(\d+)\]\s+\[([U_]+)\]`)
+	mdProgressR = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*([\d.]+)%(?:.*finish=([\d.]+)min)?(?:.*speed=(\d+K)/sec)?`)
+	mdPendingR  = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*(DELAYED|PENDING)`)
+)
+
+// mdMemberFlags are the states of the (X) flags of the members in mdstat.
+var mdMemberFlags = map[string]string{
+	"F": "faulty",
+	"S": "spare",
+	"W": "write_mostly",
+	"J": "journal",
+	"R": "replacement",
+}
+
+// Read parses a /proc/mdstat file, it returns the arrays found.
+func (l *MdstatReader) Read() ([]*RaidArray, error) {
+
+	/*
+		Personalities : [raid1] [raid6] [raid5] [raid4]
+		md1 : active raid5 sdd1[4] sdc1[2] sdb2[1] sda2[0]
+		      5860147200 blocks super 1.2 level 5, 512k chunk, algorithm 2 [4/3] [UUU_]
+		      [==>..................]  recovery = 12.6% (246883584/1953382400) finish=136.3min speed=208574K/sec
+
+		unused devices: <none>
+	*/
+
+	var ret []*RaidArray
+	var a *RaidArray
+
+	b := NewLineReader(l.r)
+	var err error
+	for {
+		line, err2 := b.ReadLine()
+		err = err2
+
+		switch {
+		case strings.HasPrefix(line, "md") && strings.Contains(line, " : "):
+			a = parseMdstatArray(line)
+			ret = append(ret, a)
+		case a != nil && strings.HasPrefix(line, " "):
+			parseMdstatStatus(a, line)
+		default:
+			a = nil
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+	return ret, err
+}
+
+// parseMdstatArray parses the first line of an array,
+// md0 : active (auto-read-only) raid1 sdb1[1] sda1[0](F).
+func parseMdstatArray(line string) *RaidArray {
+	parts := strings.SplitN(line, " : ", 2)
+	a := &RaidArray{Name: strings.TrimSpace(parts[0])}
+	a.Path = devPath(a.Name)
+	for i, f := range strings.Fields(parts[1]) {
+		m := mdMemberR.FindStringSubmatch(f)
+		switch {
+		case i == 0:
+			a.State = f
+		case m != nil:
+			member := &RaidMember{Name: m[1], Path: devPath(m[1])}
+			member.Slot, _ = strconv.Atoi(m[2])
+			for _, flag := range strings.Split(strings.Trim(m[3], "()"), ")(") {
+				if s, ok := mdMemberFlags[flag]; ok {
+					member.States = append(member.States, s)
+				}
+			}
+			if member.IsSpare() {
+				member.Slot = -1
+			} else if !member.IsFaulty() && a.State != "inactive" {
+				member.States = append(member.States, "in_sync")
+			}
+			a.Members = append(a.Members, member)
+		case strings.HasPrefix(f, "("):
+			// (auto-read-only), (read-only)
+		default:
+			a.Level = f
+		}
+	}
+	sort.SliceStable(a.Members, func(i, j int) bool {
+		return a.Members[i].Slot >= 0 && (a.Members[j].Slot < 0 || a.Members[i].Slot < a.Members[j].Slot)
+	})
+	return a
+}
+
+// parseMdstatStatus parses the status lines of an array, its devices count and its sync progress.
+func parseMdstatStatus(a *RaidArray, line string) {
+	if m := mdStatusR.FindStringSubmatch(line); m != nil {
+		a.Devices, _ = strconv.Atoi(m[1])
+		a.Active, _ = strconv.Atoi(m[2])
+		a.Degraded = a.Active < a.Devices
+		// the number of a member is its role, but for a device being recovered,
+		// numbered after the roles; such a device, or one in a missing role, is not in sync yet.
+		for _, member := range a.Members {
+			missing := member.Slot >= 0 && member.Slot < len(m[3]) && m[3][member.Slot] == '_'
+			if a.Degraded && (missing || member.Slot >= a.Devices) {
+				member.States = removeState(member.States, "in_sync")
+			}
+		}
+	}
+	if m := mdProgressR.FindStringSubmatch(line); m != nil {
+		a.SyncAction = m[1]
+		a.SyncProgress, _ = strconv.ParseFloat(m[2], 64)
+		if minutes, err := strconv.ParseFloat(m[3], 64); err == nil {
+			a.SyncRemaining = time.Duration(minutes * float64(time.Minute))
+		}
+		a.SyncSpeed, _ = ParseByteSize(m[4])
+	} else if m := mdPendingR.FindStringSubmatch(line); m != nil {
+		a.SyncAction = m[1]
+	}
+}
+
+func removeState(states []string, s string) []string {
+	var ret []string
+	for _, v := range states {
+		if v != s {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// completeFromSysfs reads the attributes of /sys/block/<md>/md, they are more precise than mdstat.
+func (a *RaidArray) completeFromSysfs(sys string) {
+	dir := filepath.Join(sys, "block", a.Name, "md")
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+	if v := readAttr(dir, "level"); v != "" {
+		a.Level = v
+	}
+	if v := readAttr(dir, "array_state"); v != "" {
+		a.State = v
+	}
+	if v, err := strconv.Atoi(readAttr(dir, "raid_disks")); err == nil {
+		a.Devices = v
+	}
+	if v, err := strconv.Atoi(readAttr(dir, "degraded")); err == nil {
+		a.Degraded = v > 0
+		a.Active = a.Devices - v
+	}
+	switch v := readAttr(dir, "sync_action"); v {
+	case "", "idle", "frozen":
+		// a frozen array does not sync.
+	case "recover":
+		// sysfs names it recover, mdstat recovery.
+		a.SyncAction = "recovery"
+	default:
+		a.SyncAction = v
+	}
+
+	entries, _ := ioutil.ReadDir(dir)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "dev-") {
+			continue
+		}
+		name := strings.TrimPrefix(e.Name(), "dev-")
+		var member *RaidMember
+		for _, m := range a.Members {
+			if m.Name == name {
+				member = m
+			}
+		}
+		if member == nil {
+			member = &RaidMember{Name: name, Path: devPath(name)}
+			a.Members = append(a.Members, member)
+		}
+		if v := readAttr(filepath.Join(dir, e.Name()), "state"); v != "" {
+			member.States = strings.Split(v, ",")
+		}
+		member.Slot = -1
+		if v, err := strconv.Atoi(readAttr(filepath.Join(dir, e.Name()), "slot")); err == nil {
+			member.Slot = v
+		}
+	}
+}
+
+// readRaidArrays reads the arrays of /proc/mdstat, completed with sysfs.
+func readRaidArrays(roots Roots) ([]*RaidArray, error) {
+	f, err := os.Open(roots.Path("/proc/mdstat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// the md module is not loaded.
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	arrays, err := NewMdstatReader(f).Read()
+	for _, a := range arrays {
+		a.completeFromSysfs(roots.Path("/sys"))
+	}
+	return arrays, err
+}
+
+// LoadRaidArrays returns the linux software RAID arrays, from /proc/mdstat and /sys/block/md*/md.
+func (l *LinuxLoader) LoadRaidArrays() ([]*RaidArray, error) {
+	return l.LoadRaidArraysContext(context.Background())
+}
+
+// LoadRaidArraysContext is LoadRaidArrays, it gives up when ctx is done.
+func (l *LinuxLoader) LoadRaidArraysContext(ctx context.Context) ([]*RaidArray, error) {
+	ret := make(chan []*RaidArray, 1)
+	err := callContext(ctx, l.SourceTimeout, func(context.Context) error {
+		arrays, err := readRaidArrays(l.Roots)
+		ret <- arrays
+		return err
+	})
+	select {
+	case arrays := <-ret:
+		return arrays, err
+	default:
+		return nil, err
+	}
+}
+
+// readRaidVolumes returns the properties of the md devices, with their array.
+func readRaidVolumes(roots Roots) ([]*Properties, error) {
+	var ret []*Properties
+	arrays, err := readRaidArrays(roots)
+	for _, a := range arrays {
+		p := NewProperties()
+		p.Path = a.Path
+		p.MajorMinor = readAttr(filepath.Join(roots.Path("/sys"), "block", a.Name), "dev")
+		p.Raid = a
+		ret = append(ret, p)
+	}
+	return ret, err
+}
@@ -0,0 +1,198 @@
+package diskinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readMdstatFixture(t *testing.T, name string) []*RaidArray {
+	f, err := os.Open(filepath.Join("testdata", "mdstat", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	arrays, err := NewMdstatReader(f).Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	return arrays
+}
+
+func memberStates(a *RaidArray) map[string][]string {
+	ret := map[string][]string{}
+	for _, m := range a.Members {
+		ret[m.Name] = m.States
+	}
+	return ret
+}
+
+func TestMdstatClean(t *testing.T) {
+	arrays := readMdstatFixture(t, "clean")
+	if len(arrays) != 2 {
+		t.Fatalf("Expected 2 arrays, got %v", len(arrays))
+	}
+	md0 := arrays[0]
+	if md0.Name != "md0" || md0.Path != "/dev/md0" || md0.Level != "raid1" || md0.State != "active" || md0.Devices != 2 || md0.Active != 2 || md0.Degraded || md0.SyncAction != "" {
+		t.Errorf("Unexpected array %#v", md0)
+	}
+	if md0.Members[0].Name != "sda1" || md0.Members[0].Slot != 0 || md0.Members[1].Name != "sdb1" {
+		t.Errorf("Expected the members in slot order, got %v %v", md0.Members[0], md0.Members[1])
+	}
+	md1 := arrays[1]
+	want := map[string][]string{"sda2": {"in_sync"}, "sdc1": {"in_sync"}, "sdd1": {"in_sync"}, "sde1": {"spare"}}
+	if md1.Level != "raid5" || md1.Degraded || !reflect.DeepEqual(memberStates(md1), want) {
+		t.Errorf("Unexpected array %#v %v", md1, memberStates(md1))
+	}
+	if spare := md1.Members[3]; !spare.IsSpare() || spare.Slot != -1 {
+		t.Errorf("Expected sde1 to be the last member, a spare, got %#v", spare)
+	}
+}
+
+func TestMdstatDegraded(t *testing.T) {
+	arrays := readMdstatFixture(t, "degraded")
+	if len(arrays) != 2 {
+		t.Fatalf("Expected 2 arrays, got %v", len(arrays))
+	}
+	md0 := arrays[0]
+	if !md0.Degraded || md0.Devices != 2 || md0.Active != 1 {
+		t.Errorf("Expected a degraded array, got %#v", md0)
+	}
+	if sdb1 := md0.Members[1]; sdb1.Name != "sdb1" || !sdb1.IsFaulty() || sdb1.hasState("in_sync") {
+		t.Errorf("Expected sdb1 to be faulty, got %#v", sdb1)
+	}
+	md127 := arrays[1]
+	if md127.State != "inactive" || md127.Level != "" || len(md127.Members) != 1 || !md127.Members[0].IsSpare() {
+		t.Errorf("Unexpected inactive array %#v", md127)
+	}
+}
+
+func TestMdstatRebuilding(t *testing.T) {
+	arrays := readMdstatFixture(t, "rebuilding")
+	if len(arrays) != 2 {
+		t.Fatalf("Expected 2 arrays, got %v", len(arrays))
+	}
+	md1 := arrays[0]
+	if !md1.Degraded || md1.SyncAction != "recovery" || md1.SyncProgress != 12.6 {
+		t.Errorf("Expected a recovering array, got %#v", md1)
+	}
+	if md1.SyncRemaining != 136*time.Minute+18*time.Second || md1.SyncSpeed != 208574*KiB {
+		t.Errorf("Unexpected sync estimates %v %v", md1.SyncRemaining, md1.SyncSpeed)
+	}
+	want := map[string][]string{"sda2": {"in_sync"}, "sdb2": {"in_sync"}, "sdc1": {"in_sync"}, "sdd1": nil}
+	if !reflect.DeepEqual(memberStates(md1), want) {
+		t.Errorf("Expected sdd1 to be rebuilt, got %v", memberStates(md1))
+	}
+	if md2 := arrays[1]; md2.Degraded || md2.SyncAction != "resync" || md2.SyncProgress != 0 {
+		t.Errorf("Expected a delayed resync, got %#v", md2)
+	}
+}
+
+func TestLinuxLoaderRaid(t *testing.T) {
+	root := t.TempDir()
+	mdstat, err := ioutil.ReadFile(filepath.Join("testdata", "mdstat", "degraded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{
+		"proc/mdstat":                       string(mdstat),
+		"proc/self/mountinfo":               "28 1 9:0 / /srv rw,relatime shared:1 - ext4 /dev/md0 rw\n",
+		"sys/block/md0/dev":                 "9:0\n",
+		"sys/block/md0/size":                "3906764928\n",
+		"sys/block/md0/md/level":            "raid1\n",
+		"sys/block/md0/md/array_state":      "clean\n",
+		"sys/block/md0/md/raid_disks":       "2\n",
+		"sys/block/md0/md/degraded":         "1\n",
+		"sys/block/md0/md/sync_action":      "idle\n",
+		"sys/block/md0/md/dev-sda1/state":   "in_sync\n",
+		"sys/block/md0/md/dev-sda1/slot":    "0\n",
+		"sys/block/md0/md/dev-sdb1/state":   "faulty,write_mostly\n",
+		"sys/block/md0/md/dev-sdb1/slot":    "none\n",
+		"sys/block/md127/md/array_state":    "inactive\n",
+		"sys/block/md127/md/raid_disks":     "0\n",
+		"sys/block/md127/md/dev-sdf1/state": "spare\n",
+		"sys/block/md127/md/dev-sdf1/slot":  "none\n",
+	})
+
+	loader := &LinuxLoader{Roots: FixtureRoots(root)}
+	res, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("Expected the md volumes only, got %#v", res)
+	}
+	if md127 := res[1]; md127.Path != "/dev/md127" || md127.Raid == nil || md127.Raid.State != "inactive" {
+		t.Errorf("Expected the inactive array to be listed, got %#v", md127)
+	}
+	a := res[0].Raid
+	if a == nil || a.State != "clean" || !a.Degraded || a.Active != 1 || a.SyncAction != "" {
+		t.Fatalf("Unexpected array %#v", a)
+	}
+	if sdb1 := a.Members[1]; !reflect.DeepEqual(sdb1.States, []string{"faulty", "write_mostly"}) || sdb1.Slot != -1 {
+		t.Errorf("Unexpected member %#v", sdb1)
+	}
+
+	arrays, err := loader.LoadRaidArrays()
+	if err != nil || len(arrays) != 2 || arrays[1].State != "inactive" {
+		t.Errorf("Unexpected arrays %#v %v", arrays, err)
+	}
+}
+
+func TestLinuxLoaderUnmountedRaid(t *testing.T) {
+	root := t.TempDir()
+	mdstat, err := ioutil.ReadFile(filepath.Join("testdata", "mdstat", "degraded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{
+		"proc/mdstat":                     string(mdstat),
+		"proc/self/mountinfo":             "28 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sdc2 rw\n",
+		"sys/block/md0/dev":               "9:0\n",
+		"sys/block/md0/size":              "3906764928\n",
+		"sys/block/md0/md/array_state":    "clean\n",
+		"sys/block/md0/md/raid_disks":     "2\n",
+		"sys/block/md0/md/degraded":       "1\n",
+		"sys/block/md0/md/dev-sda1/state": "in_sync\n",
+		"sys/block/md0/md/dev-sdb1/state": "faulty\n",
+	})
+
+	res, err := (&LinuxLoader{Roots: FixtureRoots(root)}).Load()
+	if err != nil {
+		t.Fatalf("Unexpected load error %v", err)
+	}
+	p := PropertiesList(res).FindByPath("/dev/md0")
+	if p == nil || p.Mounted || p.TotalBytes == 0 || p.SourceOf(FieldTotalBytes) != "partitions" {
+		t.Fatalf("Expected md0 to be listed unmounted, got %#v", p)
+	}
+	if p.Raid == nil || !p.Raid.Degraded || p.Raid.Active != 1 {
+		t.Errorf("Expected the degraded array, got %#v", p.Raid)
+	}
+}
+
+func TestRaidSysfsSyncAction(t *testing.T) {
+	testsTable := []struct {
+		mdstat string
+		sysfs  string
+		expect string
+	}{
+		{"", "recover", "recovery"},
+		{"", "resync", "resync"},
+		{"", "check", "check"},
+		{"", "frozen", ""},
+		{"recovery", "frozen", "recovery"},
+		{"resync", "idle", "resync"},
+	}
+	for _, test := range testsTable {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"block/md0/md/sync_action": test.sysfs + "\n"})
+		a := &RaidArray{Name: "md0", SyncAction: test.mdstat}
+		a.completeFromSysfs(root)
+		if a.SyncAction != test.expect {
+			t.Errorf("sync_action(%q): expected %q, got %q", test.sysfs, test.expect, a.SyncAction)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package diskinfo
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Roots are the directories the linux pseudo filesystems are read from.
+// An empty value stands for the live system directory.
+type Roots struct {
+	Proc string // /proc
+	Sys  string // /sys
+	Dev  string // /dev
+	Udev string // /run/udev
+}
+
+// FixtureRoots returns the roots of a fixture directory,
+// such as one extracted from a CaptureFixture archive.
+func FixtureRoots(dir string) Roots {
+	return Roots{
+		Proc: filepath.Join(dir, "proc"),
+		Sys:  filepath.Join(dir, "sys"),
+		Dev:  filepath.Join(dir, "dev"),
+		Udev: filepath.Join(dir, "run", "udev"),
+	}
+}
+
+// Path maps an absolute path of the live system to its location under the roots,
+// for example /sys/block becomes <Sys>/block.
+func (r Roots) Path(p string) string {
+	for _, x := range []struct{ prefix, root string }{
+		{"/proc", r.Proc},
+		{"/sys", r.Sys},
+		{"/dev", r.Dev},
+		{"/run/udev", r.Udev},
+	} {
+		if x.root != "" && (p == x.prefix || strings.HasPrefix(p, x.prefix+"/")) {
+			return filepath.Join(x.root, p[len(x.prefix):])
+		}
+	}
+	return p
+}
+
+// isLiveProc tells if the mount table is the one of the running system,
+// otherwise its mount points can not be inspected with statfs.
+func (r Roots) isLiveProc() bool {
+	return r.Proc == "" || filepath.Clean(r.Proc) == "/proc"
+}
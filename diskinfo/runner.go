@@ -0,0 +1,162 @@
+package diskinfo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CommandRunner runs an external command and returns its standard output.
+// The command is stopped when ctx is done.
+type CommandRunner interface {
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// CommandError is returned by a CommandRunner when a command fails,
+// it carries the standard error output of the command.
+type CommandError struct {
+	Name   string
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+// Error returns the command line, its failure and its standard error output.
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%v: %v", e.CommandLine(), e.Err)
+	if s := strings.TrimSpace(e.Stderr); s != "" {
+		msg += ": " + s
+	}
+	return msg
+}
+
+// CommandLine returns the command and its arguments joined by spaces.
+func (e *CommandError) CommandLine() string {
+	return strings.Join(append([]string{e.Name}, e.Args...), " ")
+}
+
+// ExitStatus returns the exit code of the command, or -1 if it did not exit.
+func (e *CommandError) ExitStatus() int {
+	if x, ok := e.Err.(*exec.ExitError); ok {
+		return x.ExitCode()
+	}
+	if x, ok := e.Err.(*replayExitError); ok {
+		return x.code
+	}
+	return -1
+}
+
+// ExecRunner runs commands with os/exec.
+// The standard error output is captured and attached to the returned errors.
+type ExecRunner struct{}
+
+// Run executes the command and returns its standard output.
+func (r ExecRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, &CommandError{Name: name, Args: args, Stderr: stderr.String(), Err: err}
+	}
+	return out, nil
+}
+
+// ReplayRunner serves command outputs recorded in the fixture files of a directory.
+// The output of a command is read from ReplayFile(name, args...)+".out",
+// when a ".err" file exists next to it, the command fails with that standard error output.
+type ReplayRunner struct {
+	Dir string
+}
+
+// NewReplayRunner serves the fixtures of dir.
+func NewReplayRunner(dir string) *ReplayRunner {
+	return &ReplayRunner{Dir: dir}
+}
+
+// Run returns the recorded output of the command.
+func (r *ReplayRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &CommandError{Name: name, Args: args, Err: err}
+	}
+	base := filepath.Join(r.Dir, ReplayFile(name, args...))
+	out, err := ioutil.ReadFile(base + ".out")
+	if err != nil {
+		return nil, &CommandError{Name: name, Args: args, Err: fmt.Errorf("no recorded output: %v", err)}
+	}
+	if stderr, err := ioutil.ReadFile(base + ".err"); err == nil {
+		return out, &CommandError{Name: name, Args: args, Stderr: string(stderr), Err: &replayExitError{code: 1}}
+	}
+	return out, nil
+}
+
+// RecordRunner runs commands with Runner, and records their outputs
+// into Dir for a later use by a ReplayRunner.
+type RecordRunner struct {
+	Runner CommandRunner
+	Dir    string
+}
+
+// Run executes the command and records its outputs.
+func (r *RecordRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := r.Runner.Run(ctx, name, args...)
+	base := filepath.Join(r.Dir, ReplayFile(name, args...))
+	if err2 := ioutil.WriteFile(base+".out", out, 0644); err2 != nil {
+		return out, err2
+	}
+	os.Remove(base + ".err")
+	if err != nil {
+		stderr := err.Error()
+		if x, ok := err.(*CommandError); ok {
+			stderr = x.Stderr
+		}
+		if err2 := ioutil.WriteFile(base+".err", []byte(stderr), 0644); err2 != nil {
+			return out, err2
+		}
+	}
+	return out, err
+}
+
+// ReplayFile returns the base name of the fixture files recording a command,
+// such as ls_-l_+dev+disk+by-id+ for ls -l /dev/disk/by-id/.
+// The arguments are joined with _, a / is written +, the other special characters,
+// such as _, + and the spaces, are escaped as %XX so that two commands never share a file.
+func ReplayFile(name string, args ...string) string {
+	var b strings.Builder
+	for i, arg := range append([]string{name}, args...) {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		for j := 0; j < len(arg); j++ {
+			switch c := arg[j]; {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.', c == ',':
+				b.WriteByte(c)
+			case c == '/':
+				b.WriteByte('+')
+			default:
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+	}
+	return b.String()
+}
+
+type replayExitError struct {
+	code int
+}
+
+func (e *replayExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func runnerOrDefault(r CommandRunner) CommandRunner {
+	if r == nil {
+		return ExecRunner{}
+	}
+	return r
+}
@@ -0,0 +1,97 @@
+package diskinfo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReplayFile(t *testing.T) {
+	testsTable := []struct {
+		name   string
+		args   []string
+		expect string
+	}{
+		{"ls", []string{"-l", "/dev/disk/by-id/"}, "ls_-l_+dev+disk+by-id+"},
+		{"ls", []string{"-l", "/a/b"}, "ls_-l_+a+b"},
+		{"ls", []string{"-l", "a", "b"}, "ls_-l_a_b"},
+		{"ls", []string{"-l", "a b"}, "ls_-l_a%20b"},
+		{"ls", []string{"-l", "a_b"}, "ls_-l_a%5Fb"},
+		{"ls", []string{"-l", "a+b"}, "ls_-l_a%2Bb"},
+		{"powershell", []string{"-Command", "Get-Volume | ConvertTo-Csv"}, "powershell_-Command_Get-Volume%20%7C%20ConvertTo-Csv"},
+		{"wmic", []string{"logicaldisk", "get", "caption,description,name,freespace,size"}, "wmic_logicaldisk_get_caption,description,name,freespace,size"},
+		{"lsblk", nil, "lsblk"},
+	}
+	for i, testTable := range testsTable {
+		if got := ReplayFile(testTable.name, testTable.args...); got != testTable.expect {
+			t.Errorf("Test(%v): expected %q, got %q", i, testTable.expect, got)
+		}
+	}
+}
+
+func TestReplayRunner(t *testing.T) {
+	r := NewReplayRunner("testdata/replay")
+	ctx := context.Background()
+
+	out, err := r.Run(ctx, "wmic", "logicaldisk", "get", "caption,description,drivetype,filesystem,freespace,name,size,volumename")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	res, err := NewWmicReader(bytes.NewReader(out)).Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if p := PropertiesList(res).FindByPath("E:"); p == nil || p.Label != "KINGSTON" {
+		t.Errorf("Unexpected properties %#v", p)
+	}
+
+	_, err = r.Run(ctx, "vgs", "--reportformat", "json", "--units", "b", "--nosuffix", "-o", "vg_name,vg_uuid,vg_size,vg_free")
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("Expected a CommandError, got %#v", err)
+	}
+	if cmdErr.ExitStatus() != 1 || cmdErr.Stderr == "" {
+		t.Errorf("Unexpected error %#v", cmdErr)
+	}
+	expect := "vgs --reportformat json --units b --nosuffix -o vg_name,vg_uuid,vg_size,vg_free: exit status 1: /run/lock/lvm/P_global:aux: open failed: Permission denied"
+	if cmdErr.Error() != expect {
+		t.Errorf("Unexpected error message %q", cmdErr.Error())
+	}
+
+	if _, err := r.Run(ctx, "lvs", "--reportformat", "json"); err == nil {
+		t.Errorf("Expected an error for a command not recorded")
+	}
+}
+
+type fakeRunner map[string]string
+
+func (f fakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, ok := f[ReplayFile(name, args...)]
+	if !ok {
+		return nil, &CommandError{Name: name, Args: args, Stderr: "not found", Err: errors.New("exit status 127")}
+	}
+	return []byte(out), nil
+}
+
+func TestRecordRunner(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	rec := &RecordRunner{Runner: fakeRunner{"echo_hello": "hello\n"}, Dir: dir}
+	if _, err := rec.Run(ctx, "echo", "hello"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := rec.Run(ctx, "missing"); err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	replay := NewReplayRunner(dir)
+	out, err := replay.Run(ctx, "echo", "hello")
+	if err != nil || string(out) != "hello\n" {
+		t.Errorf("Unexpected replay %q %v", out, err)
+	}
+	_, err = replay.Run(ctx, "missing")
+	if cmdErr, ok := err.(*CommandError); !ok || cmdErr.Stderr != "not found" {
+		t.Errorf("Unexpected replay error %#v", err)
+	}
+}
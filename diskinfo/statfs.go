@@ -0,0 +1,166 @@
+package diskinfo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fsUsage is the space accounting of a mounted filesystem, in bytes.
+type fsUsage struct {
+	Total      uint64
+	Free       uint64
+	Available  uint64
+	Inodes     uint64
+	InodesFree uint64
+}
+
+// statfsFunc returns the usage of the filesystem mounted at path.
+type statfsFunc func(path string) (fsUsage, error)
+
+// mountProbeWorkers is the number of mount points probed at the same time.
+const mountProbeWorkers = 16
+
+// mountProber probes the usage of the mount points with a pool of workers.
+// A probe that does not answer in time can not be interrupted, it keeps its thread
+// and its mount point busy: until it answers, the mount point is reported as timed out without a new probe.
+type mountProber struct {
+	probe statfsFunc
+	mu    sync.Mutex
+	busy  map[string]bool
+}
+
+func newMountProber(probe statfsFunc) *mountProber {
+	return &mountProber{probe: probe, busy: map[string]bool{}}
+}
+
+// liveMounts probes the mount points of the running system, for all the loads.
+var liveMounts = newMountProber(statfs)
+
+// runStatfs lists the mounted filesystems and their usage with prober,
+// it skips the pseudo filesystems without any blocks, as df does.
+// The usage is not computed when prober is nil, such as for a mount table that is not the live one.
+// A mount point that does not answer within timeout is reported as TimedOut.
+func runStatfs(ctx context.Context, roots Roots, prober *mountProber, timeout time.Duration) ([]*Properties, error) {
+	var ret []*Properties
+
+	mounts, err := readMountinfo(roots)
+	if err != nil {
+		return ret, err
+	}
+
+	usages, errs := prober.probeAll(ctx, mounts, timeout)
+	if ctx.Err() != nil {
+		return ret, ctx.Err()
+	}
+
+	for i, m := range mounts {
+		isDevice := strings.HasPrefix(m.Source, "/")
+		u, err := usages[i], errs[i]
+		timedOut := err == context.DeadlineExceeded
+		if err != nil && !isDevice && !timedOut {
+			// a pseudo filesystem we can not inspect is not worth reporting.
+			continue
+		}
+		if err == nil && u.Total == 0 && !isDevice {
+			continue
+		}
+		p := NewProperties()
+		p.Path = m.Source
+		p.MountPath = m.MountPath
+		p.Mounted = true
+		p.MajorMinor = fmt.Sprintf("%d:%d", m.Major, m.Minor)
+		p.FSType = m.FSType
+		p.MountOptions, p.ReadOnly = mergeMountOptions(m.Options, m.SuperOptions)
+		p.TimedOut = timedOut
+		if err == nil {
+			p.setUsage(u)
+		}
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+// probeAll probes the usage of the mounts with the pool of workers,
+// so that the mounts which do not answer cost a single timeout.
+// The errors are errNotLive when m is nil.
+func (m *mountProber) probeAll(ctx context.Context, mounts []*MountinfoEntry, timeout time.Duration) ([]fsUsage, []error) {
+	usages := make([]fsUsage, len(mounts))
+	errs := make([]error, len(mounts))
+	if m == nil {
+		for i := range errs {
+			errs[i] = errNotLive
+		}
+		return usages, errs
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < mountProbeWorkers && w < len(mounts); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				usages[i], errs[i] = m.probeMount(ctx, mounts[i].MountPath, timeout)
+			}
+		}()
+	}
+	for i := range mounts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return usages, errs
+}
+
+// probeMount probes the usage of the mount point at path, it gives up when ctx is done, or after timeout.
+// It returns context.DeadlineExceeded when a previous probe of path did not answer yet.
+func (m *mountProber) probeMount(ctx context.Context, path string, timeout time.Duration) (fsUsage, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return fsUsage{}, err
+	}
+	m.mu.Lock()
+	if m.busy[path] {
+		m.mu.Unlock()
+		return fsUsage{}, context.DeadlineExceeded
+	}
+	m.busy[path] = true
+	m.mu.Unlock()
+
+	var u fsUsage
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.busy, path)
+			m.mu.Unlock()
+		}()
+		u, err = m.probe(path)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return u, err
+	case <-ctx.Done():
+		return fsUsage{}, ctx.Err()
+	}
+}
+
+func (p *Properties) setUsage(u fsUsage) {
+	p.TotalBytes = ByteSize(u.Total)
+	p.FreeBytes = ByteSize(u.Free)
+	p.AvailableBytes = ByteSize(u.Available)
+	p.UsedBytes = ByteSize(u.Total - u.Free)
+	p.Inodes = u.Inodes
+	p.InodesFree = u.InodesFree
+	p.Size = p.TotalBytes.String()
+	p.SpaceLeft = p.AvailableBytes.String()
+}
@@ -0,0 +1,21 @@
+package diskinfo
+
+import "syscall"
+
+func statfs(path string) (fsUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsUsage{}, err
+	}
+	bsize := uint64(st.Frsize)
+	if bsize == 0 {
+		bsize = uint64(st.Bsize)
+	}
+	return fsUsage{
+		Total:      st.Blocks * bsize,
+		Free:       st.Bfree * bsize,
+		Available:  st.Bavail * bsize,
+		Inodes:     st.Files,
+		InodesFree: st.Ffree,
+	}, nil
+}
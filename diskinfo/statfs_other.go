@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package diskinfo
+
+import (
+	"errors"
+	"runtime"
+)
+
+func statfs(path string) (fsUsage, error) {
+	return fsUsage{}, errors.New("statfs is not supported on " + runtime.GOOS)
+}
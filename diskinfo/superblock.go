@@ -0,0 +1,39 @@
+package diskinfo
+
+import (
+	"github.com/mh-cbon/disksinfo/diskinfo/probe"
+)
+
+// readSuperblocks probes the filesystem of the partitions, and of the disks without partitions,
+// such as device mapper volumes. It finds the labels udev did not link, as in containers.
+// The devices that can not be read, or hold no known filesystem, are skipped.
+func readSuperblocks(roots Roots) ([]*Properties, error) {
+	var ret []*Properties
+
+	disks, err := NewBlockDevicesReader(roots.Path("/sys")).Read()
+	if err != nil {
+		return ret, err
+	}
+	add := func(path, majorMinor string) {
+		res, err := probe.Open(roots.Path(path))
+		if err != nil {
+			return
+		}
+		p := NewProperties()
+		p.Path = path
+		p.MajorMinor = majorMinor
+		p.FSType = res.Type
+		p.Label = res.Label
+		p.UUID = res.UUID
+		ret = append(ret, p)
+	}
+	for _, d := range disks {
+		if len(d.Partitions) == 0 {
+			add(d.Path, d.MajorMinor)
+		}
+		for _, part := range d.Partitions {
+			add(part.Path, part.MajorMinor)
+		}
+	}
+	return ret, nil
+}
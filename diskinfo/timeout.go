@@ -0,0 +1,54 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errNotLive = errors.New("not a live mount table")
+
+// DefaultMountTimeout is the time given to statfs to answer for a mount point,
+// such as a NFS mount of a dead server.
+const DefaultMountTimeout = 5 * time.Second
+
+// callContext calls f, it gives up when ctx is done, or after timeout when it is not zero.
+// An abandoned f keeps running in the background,
+// a system call blocked on a hung mount point can not be interrupted,
+// f must not write to variables the caller reads once abandoned.
+func callContext(ctx context.Context, timeout time.Duration, f func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runSource loads a source of properties within timeout.
+func runSource(ctx context.Context, timeout time.Duration, source func(ctx context.Context) ([]*Properties, error)) ([]*Properties, error) {
+	ret := make(chan []*Properties, 1)
+	err := callContext(ctx, timeout, func(ctx context.Context) error {
+		p, err := source(ctx)
+		ret <- p
+		return err
+	})
+	select {
+	case p := <-ret:
+		return p, err
+	default:
+		return nil, err
+	}
+}
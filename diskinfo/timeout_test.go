@@ -0,0 +1,148 @@
+package diskinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunStatfsTimeout(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/self/mountinfo": `23 28 0:22 / /proc rw,relatime shared:13 - proc proc rw
+28 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+40 28 0:45 / /mnt/nfs rw,relatime shared:20 - nfs4 filer:/export rw,vers=4.2
+41 28 0:46 / /mnt/backup rw,relatime shared:21 - nfs4 filer:/backup rw,vers=4.2
+42 28 0:47 / /mnt/media rw,relatime shared:22 - nfs4 filer:/media rw,vers=4.2
+`,
+	})
+
+	hung := make(chan struct{})
+	defer close(hung)
+	probe := func(path string) (fsUsage, error) {
+		switch path {
+		case "/mnt/nfs", "/mnt/backup", "/mnt/media":
+			<-hung
+			return fsUsage{}, errors.New("unreachable")
+		case "/proc":
+			return fsUsage{}, nil
+		}
+		return fsUsage{Total: 32 * uint64(GiB), Free: 4 * uint64(GiB), Available: 3 * uint64(GiB)}, nil
+	}
+
+	start := time.Now()
+	res, err := runStatfs(context.Background(), FixtureRoots(root), newMountProber(probe), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	// the hung mounts are probed at the same time, they cost a single timeout.
+	if d := time.Since(start); d >= 500*time.Millisecond {
+		t.Errorf("Expected the mounts to be probed concurrently, took %v", d)
+	}
+	l := PropertiesList(res)
+	if len(l) != 4 {
+		t.Fatalf("Expected 4 mount points, got %v", len(l))
+	}
+	if p := l.FindByPath("/dev/sda2"); p == nil || p.TimedOut || p.TotalBytes != 32*GiB || p.UsedBytes != 28*GiB {
+		t.Errorf("Unexpected root properties %#v", p)
+	}
+	if p := l.FindByPath("filer:/export"); p == nil || !p.TimedOut || p.TotalBytes != 0 || p.FSType != "nfs4" {
+		t.Errorf("Unexpected nfs properties %#v", p)
+	}
+}
+
+func TestMountProberBusy(t *testing.T) {
+	hung := make(chan struct{})
+	var mu sync.Mutex
+	calls := map[string]int{}
+	running, maxRunning := 0, 0
+	prober := newMountProber(func(path string) (fsUsage, error) {
+		mu.Lock()
+		calls[path]++
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		if path == "/mnt/nfs" {
+			<-hung
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+		return fsUsage{Total: uint64(GiB)}, nil
+	})
+
+	mounts := []*MountinfoEntry{{MountPath: "/mnt/nfs"}}
+	for i := 0; i < 100; i++ {
+		mounts = append(mounts, &MountinfoEntry{MountPath: fmt.Sprintf("/mnt/%v", i)})
+	}
+	for load := 0; load < 3; load++ {
+		_, errs := prober.probeAll(context.Background(), mounts, 50*time.Millisecond)
+		if errs[0] != context.DeadlineExceeded || errs[1] != nil {
+			t.Errorf("Load(%v): unexpected errors %v %v", load, errs[0], errs[1])
+		}
+	}
+	mu.Lock()
+	if calls["/mnt/nfs"] != 1 {
+		t.Errorf("Expected the hung mount to be probed once, got %v", calls["/mnt/nfs"])
+	}
+	// the abandoned probe of the hung mount runs beside the workers.
+	if maxRunning > mountProbeWorkers+1 {
+		t.Errorf("Expected at most %v probes at the same time, got %v", mountProbeWorkers+1, maxRunning)
+	}
+	mu.Unlock()
+
+	close(hung)
+	for i := 0; i < 100; i++ {
+		if _, err := prober.probeMount(context.Background(), "/mnt/nfs", time.Second); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	if calls["/mnt/nfs"] != 2 {
+		t.Errorf("Expected the mount to be probed again once it answered, got %v", calls["/mnt/nfs"])
+	}
+	mu.Unlock()
+}
+
+func TestLoadContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	loader := &LinuxLoader{Roots: FixtureRoots(t.TempDir())}
+	if _, err := loader.LoadContext(ctx); err != context.Canceled {
+		t.Errorf("Expected the load to be canceled, got %v", err)
+	}
+	if _, err := NewReplayRunner("testdata/replay").Run(ctx, "wmic", "logicaldisk", "get", "caption,description,drivetype,filesystem,freespace,name,size,volumename"); err == nil {
+		t.Errorf("Expected the replay to be canceled")
+	}
+}
+
+func TestRunSourceTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+
+	_, err := runSource(context.Background(), 20*time.Millisecond, func(ctx context.Context) ([]*Properties, error) {
+		<-hung
+		return nil, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the source to time out, got %v", err)
+	}
+
+	res, err := runSource(context.Background(), time.Second, func(ctx context.Context) ([]*Properties, error) {
+		return []*Properties{&Properties{Path: "/dev/sda1"}}, nil
+	})
+	if err != nil || len(res) != 1 {
+		t.Errorf("Unexpected result %v %v", res, err)
+	}
+}
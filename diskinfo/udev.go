@@ -0,0 +1,97 @@
+package diskinfo
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readUdevDB returns the properties udev recorded for the block device majorMinor,
+// such as ID_FS_TYPE or ID_FS_LABEL, from /run/udev/data/b<major>:<minor>.
+func readUdevDB(roots Roots, majorMinor string) (map[string]string, error) {
+	ret := map[string]string{}
+	f, err := os.Open(roots.Path("/run/udev/data/b" + majorMinor))
+	if err != nil {
+		return ret, err
+	}
+	defer f.Close()
+
+	b := NewLineReader(f)
+	for {
+		line, err := b.ReadLine()
+		if strings.HasPrefix(line, "E:") {
+			kv := strings.SplitN(line[2:], "=", 2)
+			if len(kv) == 2 {
+				ret[kv[0]] = kv[1]
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return ret, nil
+}
+
+// runUdevDB returns the filesystem types, the labels and identifiers of the block devices found in the udev database,
+// it helps when /dev/disk is not available, such as in containers.
+func runUdevDB(roots Roots) ([]*Properties, error) {
+	var ret []*Properties
+
+	if _, err := os.Stat(roots.Path("/run/udev/data")); os.IsNotExist(err) {
+		return ret, nil
+	}
+
+	disks, err := NewBlockDevicesReader(roots.Path("/sys")).Read()
+	if err != nil {
+		return ret, err
+	}
+
+	add := func(path, majorMinor string) {
+		db, err := readUdevDB(roots, majorMinor)
+		if err != nil {
+			return
+		}
+		p := NewProperties()
+		p.Path = path
+		p.MajorMinor = majorMinor
+		p.Label = decodeUdevEscapes(db["ID_FS_LABEL_ENC"])
+		if p.Label == "" {
+			p.Label = db["ID_FS_LABEL"]
+		}
+		p.UUID = db["ID_FS_UUID"]
+		p.FSType = db["ID_FS_TYPE"]
+		p.PartUUID = db["ID_PART_ENTRY_UUID"]
+		p.PartLabel = decodeUdevEscapes(db["ID_PART_ENTRY_NAME"])
+		if p.Label != "" || p.UUID != "" || p.FSType != "" || p.PartUUID != "" || p.PartLabel != "" {
+			ret = append(ret, p)
+		}
+	}
+	for _, d := range disks {
+		add(d.Path, d.MajorMinor)
+		for _, p := range d.Partitions {
+			add(p.Path, p.MajorMinor)
+		}
+	}
+
+	return ret, nil
+}
+
+// decodeUdevEscapes decodes the \xNN sequences udev uses
+// to encode unsafe characters, such as System\x20Reserved.
+func decodeUdevEscapes(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -1,47 +1,60 @@
 package diskinfo
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
-	"os"
-	"os/exec"
+	"io/ioutil"
 	"strings"
+	"unicode/utf16"
 )
 
-// WindowsLoader can load disk information for a windows system using wmmic.
-// Its compatile with windows 7+.
+// WindowsLoader can load disk information for a windows system using wmic or PowerShell.
+// It is compatible with windows 7+.
 type WindowsLoader struct {
+	// Runner runs the external commands, it defaults to an ExecRunner.
+	Runner CommandRunner
+	// Backend selects the commands to query, it defaults to AutoBackend.
+	Backend WindowsBackend
 }
 
-// Load queries wmmic and parses its result to return the list of partitions with their properties.
+// Load queries wmic, or PowerShell, and parses its result to return the list of partitions with their properties.
 func (w *WindowsLoader) Load() ([]*Properties, error) {
-	p := []*Properties{}
-	return p, nil
+	return w.LoadContext(context.Background())
 }
 
-func runWmic() ([]*Properties, error) {
-	var ret []*Properties
-	cmd := exec.Command("wmic", "logicaldisk", "get", "caption,description,name,freespace,size")
-	cmd.Stderr = os.Stderr
-
-	sink, err := cmd.StdoutPipe()
-	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Start(); err2 != nil {
-		return ret, err2
+// LoadContext is Load, the commands are killed when ctx is done.
+func (w *WindowsLoader) LoadContext(ctx context.Context) ([]*Properties, error) {
+	r := runnerOrDefault(w.Runner)
+	switch w.Backend {
+	case WmicBackend:
+		return runWmic(ctx, r)
+	case PowerShellBackend:
+		return runPowerShell(ctx, r)
+	case AutoBackend:
+		ret, err := runWmic(ctx, r)
+		if err == nil || ctx.Err() != nil {
+			return ret, err
+		}
+		ret, psErr := runPowerShell(ctx, r)
+		if psErr != nil && ctx.Err() == nil {
+			// both failures are reported, the wmic one may be the relevant one.
+			loadErr := &LoadError{}
+			loadErr.Errors = append(loadErr.Errors, newSourceError("wmic", err), newSourceError("powershell", psErr))
+			return ret, loadErr
+		}
+		return ret, psErr
 	}
+	return nil, fmt.Errorf("unknown windows backend %q", w.Backend)
+}
 
-	ret, err = NewWmicReader(sink).Read()
+func runWmic(ctx context.Context, r CommandRunner) ([]*Properties, error) {
+	out, err := r.Run(ctx, "wmic", "logicaldisk", "get", "caption,description,drivetype,filesystem,freespace,name,size,volumename")
 	if err != nil {
-		return ret, err
-	}
-
-	if err2 := cmd.Wait(); err2 != nil {
-		return ret, err2
+		return nil, err
 	}
-
-	return ret, err
+	return NewWmicReader(bytes.NewReader(out)).Read()
 }
 
 // WmicReader reads a wmic logicaldisk command output.
@@ -55,7 +68,8 @@ func NewWmicReader(r io.Reader) *WmicReader {
 	return &WmicReader{r: r}
 }
 
-// Read parses a wmic logicaldisk command output, it returns a list of properies for each property found.
+// Read parses a wmic logicaldisk command output, it returns a list of properties for each property found.
+// The columns are identified by their header, they can be in any order.
 func (l *WmicReader) Read() ([]*Properties, error) {
 
 	var ret []*Properties
@@ -112,52 +126,49 @@ func (l *WmicReader) Read() ([]*Properties, error) {
 	   write-host "Volume Name: " $objItem.VolumeName
 	   write-host "Volume Serial Number: " $objItem.VolumeSerialNumber
 	*/
-	i := 0
-	headersLen := []int{}
+	type column struct {
+		name  string
+		start int
+	}
+	var columns []column
 
-	b := NewLineReader(l.r)
+	b := NewLineReader(decodeWmicOutput(l.r))
 	var err error
 	for {
 		line, err2 := b.ReadLine()
 		err = err2
+		line = strings.TrimRight(line, "\r")
 
-		if line != "" {
-
-			if i == 0 {
-				headers := strings.Split(line, "")
-				last := ""
-				l := 0
-				for _, p := range headers {
-					if p != " " {
-						if last == " " {
-							headersLen = append(headersLen, l)
-						}
+		if strings.TrimSpace(line) != "" {
+			runes := []rune(line)
+
+			if columns == nil {
+				for i, r := range runes {
+					if r != ' ' && (i == 0 || runes[i-1] == ' ') {
+						columns = append(columns, column{start: i})
 					}
-					l++
-					last = p
 				}
-				headersLen = append(headersLen, l)
+				for i, c := range columns {
+					end := len(runes)
+					if i < len(columns)-1 {
+						end = columns[i+1].start
+					}
+					columns[i].name = strings.ToLower(strings.TrimSpace(string(runes[c.start:end])))
+				}
 
 			} else {
-				c := 0
-				s := []string{}
-				for e, l := range headersLen {
-					if e == 0 {
-						s = append(s, line[:l])
-					} else if e == len(headersLen)-1 {
-						s = append(s, line[c:])
-					} else {
-						s = append(s, line[c:l])
+				values := map[string]string{}
+				for i, c := range columns {
+					end := len(runes)
+					if i < len(columns)-1 && columns[i+1].start < end {
+						end = columns[i+1].start
+					}
+					if c.start < end {
+						values[c.name] = strings.TrimSpace(string(runes[c.start:end]))
 					}
-					c = l
 				}
-				p := NewProperties()
-				p.Path = strings.TrimSpace(s[0])
-				s[1] = strings.TrimSpace(s[1])
-				p.IsRemovable = s[1] == "Removable Disk"
-				ret = append(ret, p)
+				ret = append(ret, wmicProperties(values))
 			}
-			i++
 		}
 
 		if err != nil {
@@ -171,3 +182,70 @@ func (l *WmicReader) Read() ([]*Properties, error) {
 
 	return ret, err
 }
+
+// wmicProperties makes the properties of a logical disk from its wmic columns.
+func wmicProperties(values map[string]string) *Properties {
+	p := NewProperties()
+	p.Path = values["name"]
+	if p.Path == "" {
+		p.Path = values["caption"]
+	}
+	p.MountPath = values["caption"]
+	if p.MountPath == "" {
+		p.MountPath = p.Path
+	}
+	p.Mounted = true
+	p.Label = values["volumename"]
+	p.Description = values["description"]
+	p.FSType = values["filesystem"]
+	if t, ok := values["drivetype"]; ok && t != "" {
+		p.IsRemovable = t == "2"
+	} else {
+		p.IsRemovable = p.Description == "Removable Disk"
+	}
+	if v, err := ParseByteSize(values["size"]); err == nil {
+		p.TotalBytes = v
+		p.Size = v.String()
+	}
+	if v, err := ParseByteSize(values["freespace"]); err == nil {
+		p.FreeBytes = v
+		p.AvailableBytes = v
+		p.SpaceLeft = v.String()
+		if p.TotalBytes >= v {
+			p.UsedBytes = p.TotalBytes - v
+		}
+	}
+	return p
+}
+
+// decodeWmicOutput converts the UTF-16 output wmic writes
+// when it is redirected, it leaves other encodings untouched.
+func decodeWmicOutput(r io.Reader) io.Reader {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &errReader{err: err}
+	}
+	isUTF16 := len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe
+	if !isUTF16 && len(b) >= 4 && b[1] == 0 && b[3] == 0 {
+		isUTF16 = true
+	}
+	if !isUTF16 {
+		return bytes.NewReader(b)
+	}
+	if b[0] == 0xff && b[1] == 0xfe {
+		b = b[2:]
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+	}
+	return strings.NewReader(string(utf16.Decode(u)))
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
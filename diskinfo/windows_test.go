@@ -3,7 +3,10 @@ package diskinfo
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"reflect"
 	"testing"
+	"unicode/utf16"
 )
 
 func TestWmicParser(t *testing.T) {
@@ -51,3 +54,114 @@ C:       Local Fixed Disk  NTFS
 		}
 	}
 }
+
+func TestWindowsLoader(t *testing.T) {
+	loader := &WindowsLoader{Runner: NewReplayRunner("testdata/replay")}
+	res, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("Expected 4 logical disks, got %v", len(res))
+	}
+
+	expect := []*Properties{
+		&Properties{
+			Path: "C:", MountPath: "C:", Mounted: true, Label: "System", Description: "Local Fixed Disk", FSType: "NTFS",
+			TotalBytes: 135996108800, FreeBytes: 16106127360, AvailableBytes: 16106127360, UsedBytes: 119889981440,
+			Size: "127G", SpaceLeft: "15G",
+		},
+		&Properties{
+			Path: "D:", MountPath: "D:", Mounted: true, Description: "CD-ROM Disc",
+		},
+		&Properties{
+			Path: "E:", MountPath: "E:", Mounted: true, Label: "KINGSTON", Description: "Removable Disk", FSType: "FAT32", IsRemovable: true,
+			TotalBytes: 7994834944, FreeBytes: 7948206080, AvailableBytes: 7948206080, UsedBytes: 46628864,
+			Size: "7.5G", SpaceLeft: "7.5G",
+		},
+		&Properties{
+			Path: "F:", MountPath: "F:", Mounted: true, Label: "Données perso", Description: "Local Fixed Disk", FSType: "NTFS",
+			TotalBytes: 1000202039296, FreeBytes: 515396075520, AvailableBytes: 515396075520, UsedBytes: 484805963776,
+			Size: "932G", SpaceLeft: "480G",
+		},
+	}
+	for i, e := range expect {
+		if !reflect.DeepEqual(e, res[i]) {
+			t.Errorf("Disk(%v): expected\n%#v\ngot\n%#v", i, e, res[i])
+		}
+	}
+}
+
+func TestWmicParserUTF16(t *testing.T) {
+	in := "Caption  Description     \r\r\nE:       Removable Disk  \r\r\n"
+	u := utf16.Encode([]rune(in))
+	b := []byte{0xff, 0xfe}
+	for _, c := range u {
+		b = append(b, byte(c), byte(c>>8))
+	}
+
+	res, err := NewWmicReader(bytes.NewReader(b)).Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(res) != 1 || res[0].Path != "E:" || !res[0].IsRemovable {
+		t.Errorf("Unexpected properties %#v", res)
+	}
+}
+
+func TestWindowsLoaderPowerShell(t *testing.T) {
+	for _, backend := range []WindowsBackend{PowerShellBackend, AutoBackend} {
+		loader := &WindowsLoader{Runner: NewReplayRunner("testdata/replay-powershell"), Backend: backend}
+		res, err := loader.Load()
+		if err != nil {
+			t.Fatalf("Backend(%q): Unexpected error %v", backend, err)
+		}
+		l := PropertiesList(res)
+		if len(l) != 4 {
+			t.Fatalf("Backend(%q): Expected 3 volumes and the EFI partition, got %v", backend, len(l))
+		}
+
+		c := l.FindByPath("C:")
+		expect := &Properties{
+			Path: "C:", MountPath: "C:", Mounted: true, Label: "System", Description: "Fixed", FSType: "NTFS", Health: "Healthy",
+			Disk: `\\.\PhysicalDrive0`, PartitionNumber: 4, PartitionType: "ebd0a0a2-b9e5-4433-87c0-68b6b72699c7",
+			TotalBytes: 135996108800, FreeBytes: 16106127360, AvailableBytes: 16106127360, UsedBytes: 119889981440,
+			Size: "127G", SpaceLeft: "15G",
+		}
+		if !reflect.DeepEqual(expect, c) {
+			t.Errorf("Backend(%q): expected\n%#v\ngot\n%#v", backend, expect, c)
+		}
+
+		e := l.FindByPath("E:")
+		if e == nil || !e.IsRemovable || e.Health != "Warning" || e.PartitionType != "0x0c" || e.Disk != `\\.\PhysicalDrive1` {
+			t.Errorf("Backend(%q): Unexpected removable volume %#v", backend, e)
+		}
+		r := l.FindByPath(`\\?\Volume{5a1e0000-0000-0000-0000-100000000000}\`)
+		if r == nil || r.Label != "Recovery" || r.MountPath != "" || r.PartitionNumber != 1 || r.PartitionType != "de94bba4-06d1-4d40-a16a-bfd50179d6ac" {
+			t.Errorf("Backend(%q): Unexpected recovery volume %#v", backend, r)
+		}
+		if p := l.FindByPath(`\\.\Harddisk0Partition1`); p != r {
+			t.Errorf("Backend(%q): Expected the recovery partition to be its volume, got %#v", backend, p)
+		}
+		if efi := l.FindByPath(`\\.\Harddisk0Partition2`); efi == nil || efi.PartitionType != "c12a7328-f81f-11d2-ba4b-00a0c93ec93b" || efi.TotalBytes != 104857600 {
+			t.Errorf("Backend(%q): Unexpected EFI partition %#v", backend, efi)
+		}
+	}
+
+	loader := &WindowsLoader{Runner: NewReplayRunner("testdata/replay-powershell"), Backend: WmicBackend}
+	if _, err := loader.Load(); err == nil {
+		t.Errorf("Expected an error, wmic is not recorded")
+	}
+}
+
+func TestWindowsLoaderAutoBackendErrors(t *testing.T) {
+	loader := &WindowsLoader{Runner: NewReplayRunner(t.TempDir()), Backend: AutoBackend}
+	_, err := loader.Load()
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("Expected a LoadError, got %v", err)
+	}
+	if loadErr.Source("wmic") == nil || loadErr.Source("powershell") == nil {
+		t.Errorf("Expected the wmic and PowerShell errors, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 
 	"github.com/mh-cbon/disksinfo/diskinfo"
@@ -11,7 +12,9 @@ import (
 func main() {
 	loader := diskinfo.NewMultiOsLoader()
 	p, err := loader.Load()
-	if err != nil {
+	if diskinfo.IsPartial(err) {
+		fmt.Fprintln(os.Stderr, err)
+	} else if err != nil {
 		panic(err)
 	}
 	enc := json.NewEncoder(os.Stdout)